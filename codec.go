@@ -0,0 +1,271 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Codec parses a document format into the raw tree mirrored into the
+// configuration structures
+type Codec interface {
+	Decode(data []byte) (map[string]interface{}, error)
+}
+
+// Encoder is implemented by codecs able to write a raw tree back
+type Encoder interface {
+	Encode(tree map[string]interface{}) ([]byte, error)
+}
+
+// Sniffer is implemented by codecs able to recognize their format from the
+// content of a document, used by UnmarshalFile for unknown extensions
+type Sniffer interface {
+	Sniff(data []byte) bool
+}
+
+// optionsCodec is implemented by the builtin codecs supporting options
+type optionsCodec interface {
+	decode(data []byte, o *options) (map[string]interface{}, error)
+}
+
+type registeredCodec struct {
+	name  string
+	codec Codec
+}
+
+var (
+	codecsMu     sync.RWMutex
+	codecs       []registeredCodec
+	codecsByName = make(map[string]Codec)
+	codecsByExt  = make(map[string]Codec)
+)
+
+func init() {
+	RegisterCodec("json", jsonCodec{}, ".json")
+	RegisterCodec("toml", tomlCodec{}, ".toml")
+	RegisterCodec("yaml", yamlCodec{}, ".yaml", ".yml")
+}
+
+// RegisterCodec registers codec under name and the file extensions, such as
+// ".ini", replacing any codec previously registered with the same name or
+// extension. Codecs implementing Sniffer are tried in registration order
+// for files with an unknown extension, yaml decodes the files no codec
+// recognizes.
+func RegisterCodec(name string, codec Codec, extensions ...string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	replaced := false
+	for i, rc := range codecs {
+		if rc.name == name {
+			codecs[i].codec = codec
+			replaced = true
+		}
+	}
+	if !replaced {
+		codecs = append(codecs, registeredCodec{name, codec})
+	}
+
+	codecsByName[name] = codec
+	for _, ext := range extensions {
+		codecsByExt[strings.ToLower(ext)] = codec
+	}
+}
+
+// LookupCodec returns the codec registered under name
+func LookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecsByName[name]
+	return codec, ok
+}
+
+// codecForExt returns the codec registered for the extension of path
+func codecForExt(path string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecsByExt[strings.ToLower(filepath.Ext(path))]
+	return codec, ok
+}
+
+// sniffCodec returns the first registered codec recognizing data
+func sniffCodec(data []byte) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	for _, rc := range codecs {
+		if sniffer, ok := rc.codec.(Sniffer); ok && sniffer.Sniff(data) {
+			return rc.codec, true
+		}
+	}
+
+	return nil, false
+}
+
+// codecFor returns the codec of the file at path from its extension or, if
+// unknown, from its content. Yaml is the fallback, being a superset of json
+// it accepts most configuration files.
+func codecFor(path string, data []byte) (Codec, error) {
+	if codec, ok := codecForExt(path); ok {
+		return codec, nil
+	}
+
+	if codec, ok := sniffCodec(data); ok {
+		return codec, nil
+	}
+
+	if codec, ok := LookupCodec("yaml"); ok {
+		return codec, nil
+	}
+
+	return nil, fmt.Errorf("%s: no codec found", path)
+}
+
+// decodeWith parses data with codec, passing the options to the builtin
+// codecs. The trees of other codecs are normalized to string keyed maps
+// and interface slices.
+func decodeWith(codec Codec, data []byte, o *options) (map[string]interface{}, error) {
+	if oc, ok := codec.(optionsCodec); ok {
+		return oc.decode(data, o)
+	}
+
+	tree, err := codec.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeTree(tree)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %s", err)
+	}
+
+	rawmap, _ := normalized.(map[string]interface{})
+	return rawmap, nil
+}
+
+// unmarshal decodes data with codec into the configuration structure
+func unmarshal(codec Codec, data []byte, config interface{}, opts []Option) error {
+
+	o := newOptions(opts)
+
+	rawmap, err := decodeWith(codec, data, o)
+	if err != nil {
+		return err
+	}
+
+	err = decodeMapLevels(rawmap, config)
+	o.recordMetadata(rawmap, config)
+	if err != nil {
+		return fmt.Errorf("decode map: %s", err)
+	}
+
+	return nil
+}
+
+// UnmarshalFile reads the file at path and decodes it into the
+// configuration structure, with the codec registered for its extension or
+// recognizing its content.
+func UnmarshalFile(path string, config interface{}, opts ...Option) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	codec, err := codecFor(path, data)
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(codec, data, config, opts)
+}
+
+// CodecSource returns a source deep merging the document data, parsed by
+// codec, over the previous sources
+func CodecSource(codec Codec, data []byte, opts ...Option) Source {
+	return parserSource{data, func(data []byte, o *options) (map[string]interface{}, error) {
+		return decodeWith(codec, data, o)
+	}, opts}
+}
+
+// yamlCodec is the builtin yaml codec
+type yamlCodec struct{}
+
+// Decode returns the tree of a yaml document with plain strings, the yaml
+// 1.1 boolean words are only marked inside the decoding
+func (yamlCodec) Decode(data []byte) (map[string]interface{}, error) {
+	rawmap, err := parseYaml(data, newOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	return plainTree(rawmap).(map[string]interface{}), nil
+}
+
+func (yamlCodec) decode(data []byte, o *options) (map[string]interface{}, error) {
+	return parseYaml(data, o)
+}
+
+func (yamlCodec) Encode(tree map[string]interface{}) ([]byte, error) {
+	return yaml.Marshal(plainTree(tree))
+}
+
+// jsonCodec is the builtin json codec
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(data []byte) (map[string]interface{}, error) {
+	return parseJson(data, newOptions(nil))
+}
+
+func (jsonCodec) decode(data []byte, o *options) (map[string]interface{}, error) {
+	return parseJson(data, o)
+}
+
+func (jsonCodec) Encode(tree map[string]interface{}) ([]byte, error) {
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+func (jsonCodec) Sniff(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// tomlCodec is the builtin toml codec
+type tomlCodec struct{}
+
+func (tomlCodec) Decode(data []byte) (map[string]interface{}, error) {
+	return parseToml(data, newOptions(nil))
+}
+
+func (tomlCodec) decode(data []byte, o *options) (map[string]interface{}, error) {
+	return parseToml(data, o)
+}
+
+func (tomlCodec) Encode(tree map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tomlLine matches the table headers and key = value lines of toml
+var tomlLine = regexp.MustCompile(`^(\[\[?[\w."' -]+\]\]?|[\w."'-]+\s*=)`)
+
+// Sniff recognizes toml from its first significant line
+func (tomlCodec) Sniff(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return tomlLine.MatchString(line)
+	}
+	return false
+}
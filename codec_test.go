@@ -0,0 +1,149 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// propertiesCodec decodes flat name=value documents into string values
+type propertiesCodec struct{}
+
+func (propertiesCodec) Decode(data []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected name=value", i+1)
+		}
+		tree[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tree, nil
+}
+
+func (propertiesCodec) Sniff(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("; properties"))
+}
+
+func TestUnmarshalFile(t *testing.T) {
+
+	type Config struct {
+		Name string `mirror:"name"`
+		Host string `mirror:"host"`
+	}
+
+	RegisterCodec("properties", propertiesCodec{}, ".properties")
+
+	codec, ok := LookupCodec("properties")
+	assert.True(t, ok)
+	assert.Equal(t, propertiesCodec{}, codec)
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.properties": "name = props\nhost = localhost\n",
+		"config.yml":        "name: yaml\nhost: localhost\n",
+		"config.toml":       "name = \"toml\"\nhost = \"localhost\"\n",
+		"json.conf":         "{\"name\": \"json\", \"host\": \"localhost\"}",
+		"toml.conf":         "# comment\nname = \"toml\"\nhost = \"localhost\"\n",
+		"yaml.conf":         "name: yaml\nhost: localhost\n",
+		"props.conf":        "; properties\nname = props\nhost = localhost\n",
+	}
+
+	want := map[string]string{
+		"config.properties": "props",
+		"config.yml":        "yaml",
+		"config.toml":       "toml",
+		"json.conf":         "json",
+		"toml.conf":         "toml",
+		"yaml.conf":         "yaml",
+		"props.conf":        "props",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+		var config Config
+		err := UnmarshalFile(path, &config)
+
+		assert.NoError(t, err, name)
+		assert.Equal(t, Config{Name: want[name], Host: "localhost"}, config, name)
+	}
+
+	err = UnmarshalFile(filepath.Join(dir, "missing.yaml"), &Config{})
+	assert.Error(t, err)
+}
+
+func TestCodecEncode(t *testing.T) {
+
+	tree := map[string]interface{}{
+		"name": "encoded",
+		"server": map[string]interface{}{
+			"host": "localhost",
+		},
+	}
+
+	for _, name := range []string{"yaml", "json", "toml"} {
+		codec, ok := LookupCodec(name)
+		assert.True(t, ok)
+
+		data, err := codec.(Encoder).Encode(tree)
+		assert.NoError(t, err, name)
+
+		decoded, err := codec.Decode(data)
+		assert.NoError(t, err, name)
+		assert.Equal(t, tree, decoded, name)
+	}
+}
+
+// nestedCodec returns the trees of yaml.v2 like parsers
+type nestedCodec struct {
+	tree map[string]interface{}
+}
+
+func (c nestedCodec) Decode(data []byte) (map[string]interface{}, error) {
+	return c.tree, nil
+}
+
+func TestDecodeWithNormalizes(t *testing.T) {
+
+	type Config struct {
+		Server struct {
+			Host string   `mirror:"host"`
+			Tags []string `mirror:"tags"`
+		} `mirror:"server"`
+	}
+
+	codec := nestedCodec{map[string]interface{}{
+		"server": map[interface{}]interface{}{
+			"host": "localhost",
+			"tags": []interface{}{"a"},
+		},
+	}}
+
+	var config Config
+	err := unmarshal(codec, nil, &config, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", config.Server.Host)
+	assert.Equal(t, []string{"a"}, config.Server.Tags)
+
+	codec = nestedCodec{map[string]interface{}{
+		"server": map[interface{}]interface{}{1: "localhost"},
+	}}
+
+	err = unmarshal(codec, nil, &config, nil)
+	assert.EqualError(t, err, "decode: non string key '1'")
+}
@@ -0,0 +1,285 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChangeKind is the kind of a Change
+type ChangeKind int
+
+const (
+	// ChangeModified is a value modified in place
+	ChangeModified ChangeKind = iota
+	// ChangeAdded is a value present only in the new configuration
+	ChangeAdded
+	// ChangeRemoved is a value present only in the old configuration
+	ChangeRemoved
+	// ChangeDynamicType is a dynamic field whose selected type changed
+	ChangeDynamicType
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeModified:
+		return "modified"
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeDynamicType:
+		return "dynamic type changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single difference between two configurations
+type Change struct {
+	// Path is the path of the value built from the `mirror` tags,
+	// as in server.tls.cert or plugins[1].name
+	Path string
+	Old  interface{}
+	New  interface{}
+	Kind ChangeKind
+	// Restart is set when the value belongs to a field tagged with
+	// `reload=restart`, which cannot change at runtime
+	Restart bool
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s '%v' -> '%v'", c.Path, c.Kind, c.Old, c.New)
+}
+
+// Diff returns the field level changes between the configurations old and
+// new, which must have the same type. Dynamic fields whose selector differs
+// are reported as a single ChangeDynamicType change.
+func Diff(old, new interface{}) []Change {
+	changes := []Change{}
+	diffValues("", reflect.ValueOf(old), reflect.ValueOf(new), false, &changes)
+	return changes
+}
+
+// diffValues appends to changes the differences between old and new
+func diffValues(name string, old, new reflect.Value, restart bool, changes *[]Change) {
+	if !old.IsValid() || !new.IsValid() {
+		diffPresence(name, old, new, restart, changes)
+		return
+	}
+
+	if old.Type() != new.Type() {
+		*changes = append(*changes, Change{name, old.Interface(), new.Interface(), ChangeDynamicType, restart})
+		return
+	}
+
+	switch old.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if old.IsNil() || new.IsNil() {
+			diffPresence(name, valueOrInvalid(old), valueOrInvalid(new), restart, changes)
+			return
+		}
+
+		if old.Kind() == reflect.Interface && old.Elem().Type() != new.Elem().Type() {
+			*changes = append(*changes, Change{name, old.Interface(), new.Interface(), ChangeDynamicType, restart})
+			return
+		}
+
+		diffValues(name, old.Elem(), new.Elem(), restart, changes)
+
+	case reflect.Struct:
+		// Optional values and structs without tagged fields, such as
+		// time.Time, are compared as a whole
+		if old.Type() == optionalType || opaqueStruct(old.Type()) {
+			if !leafEqual(old, new) {
+				*changes = append(*changes, Change{name, old.Interface(), new.Interface(), ChangeModified, restart})
+			}
+			return
+		}
+
+		diffStructs(name, old, new, restart, changes)
+
+	case reflect.Slice, reflect.Array:
+		if old.Kind() == reflect.Slice && (old.IsNil() || new.IsNil()) && old.Len()+new.Len() > 0 {
+			diffPresence(name, valueOrInvalid(old), valueOrInvalid(new), restart, changes)
+			return
+		}
+
+		for i := 0; i < old.Len() || i < new.Len(); i++ {
+			fieldName := name + "[" + strconv.Itoa(i) + "]"
+			diffPresence(fieldName, indexOrInvalid(old, i), indexOrInvalid(new, i), restart, changes)
+		}
+
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, key := range append(old.MapKeys(), new.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			fieldName := key
+			if name != "" {
+				fieldName = name + "." + key
+			}
+
+			diffValues(fieldName, old.MapIndex(keys[key]), new.MapIndex(keys[key]), restart, changes)
+		}
+
+	default:
+		if !leafEqual(old, new) {
+			*changes = append(*changes, Change{name, old.Interface(), new.Interface(), ChangeModified, restart})
+		}
+	}
+}
+
+// opaqueStruct reports if the struct type typ has no exported field with a
+// `mirror` tag
+func opaqueStruct(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath == "" && field.Tag.Get("mirror") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// leafEqual reports if the values old and new, compared as a whole, are
+// equal. Times are equal when they denote the same instant.
+func leafEqual(old, new reflect.Value) bool {
+	if t, ok := old.Interface().(time.Time); ok {
+		return t.Equal(new.Interface().(time.Time))
+	}
+	return reflect.DeepEqual(old.Interface(), new.Interface())
+}
+
+// diffStructs appends the differences between the tagged fields of the
+// structs old and new
+func diffStructs(name string, old, new reflect.Value, restart bool, changes *[]Change) {
+	typ := old.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		tag, _ := parseTag(field)
+		key := tag.Name
+		if key == "" {
+			key = field.Name
+		}
+
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
+
+		fieldRestart := restart || tag.Reload == reloadRestart
+
+		if tag.Dynamic != "" {
+			diffDynamic(fieldName, old.Field(i), new.Field(i), old, new, tag.Dynamic, fieldRestart, changes)
+			continue
+		}
+
+		diffValues(fieldName, old.Field(i), new.Field(i), fieldRestart, changes)
+	}
+}
+
+// diffDynamic appends the differences between the values of a dynamic field,
+// either a dynamic struct or a slice of them, held by the structs oldParent
+// and newParent, reporting a ChangeDynamicType for every value whose
+// selector differs.
+func diffDynamic(name string, old, new, oldParent, newParent reflect.Value, selector string, restart bool, changes *[]Change) {
+	kind := reflect.Indirect(old).Kind()
+	if kind != reflect.Slice && kind != reflect.Array {
+		if dynamicSelectorDiffers(old, new, oldParent, newParent, selector) {
+			*changes = append(*changes, Change{name, old.Interface(), new.Interface(), ChangeDynamicType, restart})
+			return
+		}
+
+		diffValues(name, old, new, restart, changes)
+		return
+	}
+
+	old, new = reflect.Indirect(old), reflect.Indirect(new)
+	if !old.IsValid() || !new.IsValid() {
+		diffValues(name, old, new, restart, changes)
+		return
+	}
+
+	for i := 0; i < old.Len() || i < new.Len(); i++ {
+		fieldName := name + "[" + strconv.Itoa(i) + "]"
+
+		if i >= old.Len() || i >= new.Len() {
+			diffPresence(fieldName, indexOrInvalid(old, i), indexOrInvalid(new, i), restart, changes)
+			continue
+		}
+
+		diffDynamic(fieldName, old.Index(i), new.Index(i), oldParent, newParent, selector, restart, changes)
+	}
+}
+
+// dynamicSelectorDiffers reports if the selector fields of two values of a
+// dynamic struct, or of their parents, differ
+func dynamicSelectorDiffers(old, new, oldParent, newParent reflect.Value, selector string) bool {
+	fromParent, keys := selectorPath(selector)
+	if fromParent {
+		old, new = oldParent, newParent
+	}
+
+	for _, key := range keys {
+		old, new = reflect.Indirect(old), reflect.Indirect(new)
+		if !old.IsValid() || !new.IsValid() || old.Kind() != reflect.Struct {
+			return false
+		}
+
+		field, _, ok := structFieldByTag(old.Type(), key)
+		if !ok {
+			return false
+		}
+
+		old, new = old.FieldByIndex(field.Index), new.FieldByIndex(field.Index)
+	}
+
+	return !reflect.DeepEqual(old.Interface(), new.Interface())
+}
+
+// diffPresence appends the change of a value present on one side only
+func diffPresence(name string, old, new reflect.Value, restart bool, changes *[]Change) {
+	switch {
+	case old.IsValid() && new.IsValid():
+		diffValues(name, old, new, restart, changes)
+	case new.IsValid():
+		*changes = append(*changes, Change{name, nil, new.Interface(), ChangeAdded, restart})
+	case old.IsValid():
+		*changes = append(*changes, Change{name, old.Interface(), nil, ChangeRemoved, restart})
+	}
+}
+
+// valueOrInvalid returns the invalid value for nil pointers, interfaces and
+// slices
+func valueOrInvalid(val reflect.Value) reflect.Value {
+	if val.IsNil() {
+		return reflect.Value{}
+	}
+	return val
+}
+
+// indexOrInvalid returns the item i of a slice or the invalid value when
+// out of range
+func indexOrInvalid(val reflect.Value, i int) reflect.Value {
+	if i >= val.Len() {
+		return reflect.Value{}
+	}
+	return val.Index(i)
+}
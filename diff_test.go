@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type DiffTLS struct {
+	Cert string `mirror:"cert"`
+}
+
+type DiffServer struct {
+	Port int      `mirror:"port"`
+	TLS  *DiffTLS `mirror:"tls"`
+}
+
+type DiffConfig struct {
+	Server   DiffServer        `mirror:"server"`
+	Tags     []string          `mirror:"tags"`
+	Scores   [2]int            `mirror:"scores"`
+	Labels   map[string]string `mirror:"labels"`
+	Backend  DynTyp            `mirror:"backend,dynamic=type"`
+	Backends []DynTyp          `mirror:"backends,dynamic=type"`
+}
+
+func TestDiff(t *testing.T) {
+
+	old := DiffConfig{
+		Server:   DiffServer{Port: 80, TLS: &DiffTLS{Cert: "a.pem"}},
+		Tags:     []string{"a", "b"},
+		Scores:   [2]int{1, 2},
+		Labels:   map[string]string{"app": "mirror", "env": "dev"},
+		Backend:  DynTyp{Type: "int", Value: 1},
+		Backends: []DynTyp{{Type: "int", Value: 1}, {Type: "int", Value: 2}},
+	}
+
+	new := DiffConfig{
+		Server:   DiffServer{Port: 80, TLS: &DiffTLS{Cert: "b.pem"}},
+		Tags:     []string{"a", "c", "d"},
+		Scores:   [2]int{1, 3},
+		Labels:   map[string]string{"app": "mirror", "tier": "web"},
+		Backend:  DynTyp{Type: "string", Value: "1"},
+		Backends: []DynTyp{{Type: "int", Value: 5}, {Type: "string", Value: "2"}},
+	}
+
+	want := []Change{
+		{"server.tls.cert", "a.pem", "b.pem", ChangeModified, false},
+		{"tags[1]", "b", "c", ChangeModified, false},
+		{"tags[2]", nil, "d", ChangeAdded, false},
+		{"scores[1]", 2, 3, ChangeModified, false},
+		{"labels.env", "dev", nil, ChangeRemoved, false},
+		{"labels.tier", nil, "web", ChangeAdded, false},
+		{"backend", old.Backend, new.Backend, ChangeDynamicType, false},
+		{"backends[0].value", 1, 5, ChangeModified, false},
+		{"backends[1]", old.Backends[1], new.Backends[1], ChangeDynamicType, false},
+	}
+
+	assert.Equal(t, want, Diff(&old, &new))
+	assert.Empty(t, Diff(&old, &old))
+
+	new.Server.TLS = nil
+	assert.Equal(t, Change{"server.tls", old.Server.TLS, nil, ChangeRemoved, false}, Diff(old, new)[0])
+	assert.Equal(t, "server.tls: removed '&{a.pem}' -> '<nil>'", Diff(old, new)[0].String())
+}
+
+func TestDiffRestart(t *testing.T) {
+
+	type Config struct {
+		Listen  string     `mirror:"listen,reload=restart"`
+		Server  DiffServer `mirror:"server,reload=restart"`
+		Workers int        `mirror:"workers"`
+	}
+
+	old := Config{Listen: ":80", Server: DiffServer{Port: 80}, Workers: 1}
+	new := Config{Listen: ":81", Server: DiffServer{Port: 81}, Workers: 2}
+
+	want := []Change{
+		{"listen", ":80", ":81", ChangeModified, true},
+		{"server.port", 80, 81, ChangeModified, true},
+		{"workers", 1, 2, ChangeModified, false},
+	}
+
+	assert.Equal(t, want, Diff(old, new))
+}
+
+func TestDiffTime(t *testing.T) {
+
+	type Config struct {
+		At time.Time `mirror:"at"`
+	}
+
+	at := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	later := at.Add(time.Hour)
+
+	assert.Equal(t, []Change{{"at", at, later, ChangeModified, false}}, Diff(Config{at}, Config{later}))
+	assert.Equal(t, []Change{}, Diff(Config{at}, Config{at.In(time.FixedZone("CET", 3600))}))
+}
@@ -0,0 +1,189 @@
+package mirror
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// LoadDir loads every file of the directory dir in fsys with the extension
+// of a registered codec (*.yaml, *.yml, *.json, *.toml...), in lexical
+// order, merges them as Load does and decodes the result into config.
+// Unknown keys and keys whose value changes from a map to a different kind
+// are reported with the file that introduced them.
+func LoadDir(fsys fs.FS, dir string, config interface{}, opts ...Option) error {
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("load dir: config must be a non nil pointer")
+	}
+	typ := val.Type().Elem()
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("load dir: %s", err)
+	}
+
+	o := newOptions(opts)
+	raw := make(map[string]interface{})
+	origins := make(map[string]string)
+	errors := make([]string, 0)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		codec, ok := codecForExt(entry.Name())
+		if !ok {
+			continue
+		}
+
+		file := path.Join(dir, entry.Name())
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("load dir: %s", err)
+		}
+
+		tree, err := decodeWith(codec, data, o)
+		if err != nil {
+			return fmt.Errorf("load dir: %s: %s", file, err)
+		}
+
+		for _, key := range unknownKeys("", tree, typ) {
+			errors = append(errors, file+": unknown key: "+key)
+		}
+
+		for _, conflict := range conflictingKeys("", raw, tree, origins) {
+			errors = append(errors, file+": "+conflict)
+		}
+
+		recordOrigins("", tree, file, origins)
+
+		merged, err := mergeTree("", raw, tree, typ)
+		if err != nil {
+			return fmt.Errorf("load dir: %s: %s", file, err)
+		}
+		raw = merged.(map[string]interface{})
+	}
+
+	if len(errors) > 0 {
+		o.recordMetadata(raw, config)
+		return &Error{errors}
+	}
+
+	err = decodeMapLevels(raw, config)
+	o.recordMetadata(raw, config)
+	if err != nil {
+		return fmt.Errorf("decode map: %s", err)
+	}
+
+	return nil
+}
+
+// unknownKeys returns the sorted paths of the keys of data not matching any
+// tagged field of typ. Interfaces and structs without tagged fields, such
+// as Optional, hold any keys.
+func unknownKeys(name string, data interface{}, typ reflect.Type) []string {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ == nil {
+		return nil
+	}
+
+	keys := []string{}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		dataMap, ok := data.(map[string]interface{})
+		if !ok || typ == optionalType || opaqueStruct(typ) {
+			return nil
+		}
+
+		for key, value := range dataMap {
+			fieldName := key
+			if name != "" {
+				fieldName = name + "." + key
+			}
+
+			field, tag, ok := structFieldByTag(typ, key)
+			if !ok {
+				keys = append(keys, fieldName)
+				continue
+			}
+
+			// The keys of unions depend on their type, checked by decoding
+			if tag.Union != "" {
+				continue
+			}
+
+			keys = append(keys, unknownKeys(fieldName, value, field.Type)...)
+		}
+
+	case reflect.Slice, reflect.Array:
+		dataSlice, ok := data.([]interface{})
+		if !ok {
+			return nil
+		}
+
+		for i, value := range dataSlice {
+			keys = append(keys, unknownKeys(name+"["+strconv.Itoa(i)+"]", value, typ.Elem())...)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// conflictingKeys returns the keys of overlay replacing a map of base with
+// a value of a different kind, or the opposite
+func conflictingKeys(name string, base, overlay map[string]interface{}, origins map[string]string) []string {
+	conflicts := []string{}
+
+	for key, value := range overlay {
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
+
+		baseValue, ok := base[key]
+		if !ok {
+			continue
+		}
+
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+
+		switch {
+		case baseIsMap && valueIsMap:
+			conflicts = append(conflicts, conflictingKeys(fieldName, baseMap, valueMap, origins)...)
+		case baseIsMap != valueIsMap:
+			conflicts = append(conflicts, fmt.Sprintf(
+				"key '%s' conflicts with the value set in %s", fieldName, origins[fieldName]))
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// recordOrigins records file as the last one setting the keys of tree
+func recordOrigins(name string, tree map[string]interface{}, file string, origins map[string]string) {
+	for key, value := range tree {
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
+
+		origins[fieldName] = file
+
+		if valueMap, ok := value.(map[string]interface{}); ok {
+			recordOrigins(fieldName, valueMap, file, origins)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadDir(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"conf.d/00-base.yaml":  {Data: []byte("name: base\nserver:\n  host: localhost\n  port: 8080\ntags: [a]\n")},
+		"conf.d/10-host.json":  {Data: []byte(`{"server": {"host": "example.org"}}`)},
+		"conf.d/20-tags.yml":   {Data: []byte("tags: [b]\nserver:\n  port: 9090\n")},
+		"conf.d/README.md":     {Data: []byte("not a config")},
+		"conf.d/sub/99.yaml":   {Data: []byte("name: ignored\n")},
+		"conf.d/30-name.yaml":  {Data: []byte("name: override\n")},
+		"conf.d/40-dyn.yaml":   {Data: []byte("backend:\n  type: int\n  value: 1\n")},
+		"other.d/00-base.yaml": {Data: []byte("name: other\n")},
+	}
+
+	want := LoadConfig{
+		Name:    "override",
+		Server:  LoadServer{Host: "example.org", Port: 9090},
+		Tags:    []string{"b"},
+		Backend: DynTyp{Type: "int", Value: 1},
+	}
+
+	var config LoadConfig
+	err := LoadDir(fsys, "conf.d", &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+}
+
+func TestLoadDirErrors(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"conf.d/00-base.yaml":    {Data: []byte("name: base\nserver:\n  host: localhost\n  port: 8080\n")},
+		"conf.d/10-typo.yaml":    {Data: []byte("server:\n  prot: 9090\ntgas: [a]\n")},
+		"conf.d/20-conflict.yml": {Data: []byte("server: localhost\n")},
+	}
+
+	wanterr := &Error{
+		Errors: []string{
+			"conf.d/10-typo.yaml: unknown key: server.prot",
+			"conf.d/10-typo.yaml: unknown key: tgas",
+			"conf.d/20-conflict.yml: key 'server' conflicts with the value set in conf.d/10-typo.yaml",
+		},
+	}
+
+	var config LoadConfig
+	err := LoadDir(fsys, "conf.d", &config)
+
+	assert.Equal(t, wanterr, err)
+
+	err = LoadDir(fsys, "missing.d", &config)
+	assert.Error(t, err)
+}
+
+type LoadOpaque struct {
+	Name string   `mirror:"name"`
+	Opt  Optional `mirror:"o"`
+}
+
+func TestLoadDirOpaqueKeys(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"conf.d/a.yaml": {Data: []byte("name: a\no:\n  a: 1\n")},
+	}
+
+	var config LoadOpaque
+	err := LoadDir(fsys, "conf.d", &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "a", config.Name)
+	assert.Equal(t, Optional{State: KeySet, Raw: map[string]interface{}{"a": 1}}, config.Opt)
+}
@@ -0,0 +1,244 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// dynamicType is a single registered dynamic payload
+type dynamicType struct {
+	name string
+	typ  reflect.Type
+}
+
+var (
+	dynamicTypesMu sync.RWMutex
+	dynamicTypes   = make(map[reflect.Type][]dynamicType)
+)
+
+// RegisterDynamicType records that the DynamicStruct dyn holds a payload
+// of the same type as value when its selector is equal to name. It allows
+// tools like JSONSchema to enumerate every possible type of a dynamic field.
+// Once a type is registered for dyn, decoding rejects the selectors not
+// registered for it, suggesting the closest name. Unregistered dynamic
+// structs accept any selector and only rely on SetDynamicType.
+func RegisterDynamicType(dyn DynamicStruct, name string, value interface{}) {
+	dynType := reflect.Indirect(reflect.ValueOf(dyn)).Type()
+
+	dynamicTypesMu.Lock()
+	defer dynamicTypesMu.Unlock()
+
+	for i, dt := range dynamicTypes[dynType] {
+		if dt.name == name {
+			dynamicTypes[dynType][i].typ = reflect.TypeOf(value)
+			return
+		}
+	}
+	dynamicTypes[dynType] = append(dynamicTypes[dynType], dynamicType{name, reflect.TypeOf(value)})
+}
+
+// registeredDynamicTypes returns the dynamic types registered for the
+// struct type typ, in registration order.
+func registeredDynamicTypes(typ reflect.Type) []dynamicType {
+	dynamicTypesMu.RLock()
+	defer dynamicTypesMu.RUnlock()
+
+	return append([]dynamicType(nil), dynamicTypes[typ]...)
+}
+
+// checkDynamicType returns an error if types are registered for the
+// dynamic struct type typ and none of them is named value, suggesting the
+// closest registered name
+func checkDynamicType(name string, typ reflect.Type, value string) error {
+	registered := registeredDynamicTypes(typ)
+	if len(registered) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(registered))
+	for i, dt := range registered {
+		if dt.name == value {
+			return nil
+		}
+		names[i] = dt.name
+	}
+
+	if suggestion, ok := closestName(value, names); ok {
+		return fmt.Errorf("'%s' unknown dynamic type '%s', did you mean '%s'?", name, value, suggestion)
+	}
+
+	return fmt.Errorf("'%s' unknown dynamic type '%s', known types: %s", name, value, strings.Join(names, ", "))
+}
+
+// parentSelector is the prefix of the dynamic selectors looked up in the
+// map holding the dynamic field, such as `dynamic=../kind`
+const parentSelector = "../"
+
+// selectorPath splits a dynamic selector into the keys leading to its
+// value, reporting if the lookup starts from the parent map
+func selectorPath(selector string) (bool, []string) {
+	parent := strings.HasPrefix(selector, parentSelector)
+	return parent, strings.Split(strings.TrimPrefix(selector, parentSelector), ".")
+}
+
+// validSelector reports if selector is a valid dynamic selector, a dot
+// separated key path optionally prefixed by ../
+func validSelector(selector string) bool {
+	_, keys := selectorPath(selector)
+	for _, key := range keys {
+		if key == "" || strings.Contains(key, "/") {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupSelector returns the value of the selector in the raw map data of
+// a dynamic struct, or in parent, the raw map holding the dynamic field
+func lookupSelector(data, parent reflect.Value, selector string) reflect.Value {
+	fromParent, keys := selectorPath(selector)
+	if fromParent {
+		data = parent
+	}
+
+	for _, key := range keys {
+		for data.Kind() == reflect.Interface && !data.IsNil() {
+			data = data.Elem()
+		}
+
+		if data.Kind() != reflect.Map {
+			return reflect.Value{}
+		}
+
+		data = data.MapIndex(reflect.ValueOf(key))
+	}
+
+	return data
+}
+
+// dynamicSelector returns the type selected by the raw map data of a
+// dynamic struct, held by the raw map parent, or the default type of the
+// tag when the selector is absent. Integer and boolean selectors are
+// returned in canonical string form.
+func dynamicSelector(name string, data, parent reflect.Value, tag fieldTag) (string, bool, error) {
+	value := lookupSelector(data, parent, tag.Dynamic)
+
+	if !value.IsValid() || isNullValue(value) {
+		return tag.DynDefault, tag.DynDefault != "", nil
+	}
+
+	dynType, ok := selectorString(value.Interface())
+	if !ok {
+		return "", false, fmt.Errorf(
+			"'%s' dynamic selector '%s' must be a string, an integer or a boolean, got '%v'",
+			name, tag.Dynamic, value.Interface())
+	}
+
+	return dynType, true, nil
+}
+
+// defaultSelector returns the raw data of a dynamic field of type typ, a map
+// or a slice of maps, with the default selector of tag set where it is
+// absent, so that the selector field decodes the default type. Parent
+// selectors, selectors not mapped to a field and Optional selector fields,
+// which record the absence of their key, are left alone.
+func defaultSelector(data interface{}, typ reflect.Type, tag fieldTag) interface{} {
+	fromParent, keys := selectorPath(tag.Dynamic)
+	if tag.DynDefault == "" || fromParent {
+		return data
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch d := data.(type) {
+	case []interface{}:
+		if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+			return data
+		}
+
+		elems := make([]interface{}, len(d))
+		for i, elem := range d {
+			elems[i] = defaultSelector(elem, typ.Elem(), tag)
+		}
+		return elems
+
+	case map[string]interface{}:
+		if withDefault, ok := setDefaultSelector(d, typ, keys, tag.DynDefault); ok {
+			return withDefault
+		}
+	}
+
+	return data
+}
+
+// setDefaultSelector returns a copy of the raw map data of the struct type
+// typ with the selector at the key path keys set to dynDefault, when absent
+func setDefaultSelector(data map[string]interface{}, typ reflect.Type, keys []string, dynDefault string) (map[string]interface{}, bool) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	field, _, ok := structFieldByTag(typ, keys[0])
+	if !ok {
+		return nil, false
+	}
+
+	copied := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		copied[key] = value
+	}
+
+	if len(keys) > 1 {
+		nested, ok := data[keys[0]].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		value, ok := setDefaultSelector(nested, field.Type, keys[1:], dynDefault)
+		if !ok {
+			return nil, false
+		}
+		copied[keys[0]] = value
+		return copied, true
+	}
+
+	if data[keys[0]] != nil || field.Type == optionalType {
+		return nil, false
+	}
+
+	value, err := convertScalar(keys[0], dynDefault, field.Type)
+	if err != nil {
+		return nil, false
+	}
+	copied[keys[0]] = value
+
+	return copied, true
+}
+
+// selectorString returns the canonical string form of a selector value
+func selectorString(value interface{}) (string, bool) {
+	val := reflect.ValueOf(value)
+
+	switch getKind(val) {
+	case reflect.String:
+		return val.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool()), true
+	case reflect.Int:
+		return strconv.FormatInt(val.Int(), 10), true
+	case reflect.Uint:
+		return strconv.FormatUint(val.Uint(), 10), true
+	case reflect.Float64:
+		// Numbers parsed from json are float64
+		if isIntegral(val.Float()) {
+			return strconv.FormatInt(int64(val.Float()), 10), true
+		}
+	}
+
+	return "", false
+}
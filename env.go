@@ -0,0 +1,216 @@
+package mirror
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envSource is a source overriding keys from environment variables
+type envSource struct {
+	prefix    string
+	separator string
+}
+
+// EnvSource returns a source overriding the keys of the previous sources
+// with environment variables. Variable names are derived from the path of
+// the `mirror` tags: with prefix APP and separator _ the key server.port is
+// read from APP_SERVER_PORT and the name of the first plugin from
+// APP_PLUGINS_0_NAME. The `env=NAME` tag option replaces the derived name of
+// a field. Values are converted to the kind of the target field.
+func EnvSource(prefix, separator string) Source {
+	if separator == "" {
+		separator = "_"
+	}
+	return envSource{prefix, separator}
+}
+
+func (e envSource) Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error) {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	errors := make([]string, 0)
+
+	value, _ := e.apply(env, e.prefix, raw, typ, &errors)
+
+	if len(errors) > 0 {
+		return nil, &Error{errors}
+	}
+
+	return value.(map[string]interface{}), nil
+}
+
+// varName joins the variable name of the parent with the key of a child
+func (e envSource) varName(parent, key string) string {
+	key = strings.ToUpper(strings.Replace(key, "-", "_", -1))
+	if parent == "" {
+		return key
+	}
+	return parent + e.separator + key
+}
+
+// apply overrides data, decoding to typ, with the variables named after
+// varName and reports if any variable was found.
+func (e envSource) apply(env map[string]string, varName string, data interface{}, typ reflect.Type, errors *[]string) (interface{}, bool) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch getTypeKind(typ) {
+	case reflect.Struct:
+		dataMap, _ := data.(map[string]interface{})
+
+		merged := make(map[string]interface{}, len(dataMap))
+		for key, value := range dataMap {
+			merged[key] = value
+		}
+
+		found := false
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+
+			tag, err := parseTag(field)
+			if err != nil || tag.Name == "" || tag.Union != "" {
+				continue
+			}
+
+			fieldVarName := tag.Env
+			if fieldVarName == "" {
+				fieldVarName = e.varName(varName, tag.Name)
+			}
+
+			value, ok := e.apply(env, fieldVarName, merged[tag.Name], field.Type, errors)
+			if ok {
+				merged[tag.Name] = value
+				found = true
+			}
+		}
+
+		if !found {
+			return data, false
+		}
+		return merged, true
+
+	case reflect.Slice, reflect.Array:
+		dataSlice, _ := data.([]interface{})
+		merged := append([]interface{}(nil), dataSlice...)
+
+		found := false
+		for _, index := range e.indexes(env, varName) {
+			indexVarName := varName + e.separator + strconv.Itoa(index)
+
+			// Indexes may append a single item, as in SetSource, the sorted
+			// indexes fill the list without gaps
+			if index > len(merged) {
+				*errors = append(*errors, fmt.Sprintf("'%s' index out of range, list has %d items", indexVarName, len(merged)))
+				continue
+			}
+			if index == len(merged) {
+				merged = append(merged, nil)
+			}
+
+			value, ok := e.apply(env, indexVarName, merged[index], typ.Elem(), errors)
+			if ok {
+				merged[index] = value
+				found = true
+			}
+		}
+
+		if !found {
+			return data, false
+		}
+		return merged, true
+
+	default:
+		value, ok := env[varName]
+		if !ok {
+			return data, false
+		}
+
+		converted, err := convertScalar(varName, value, typ)
+		if err != nil {
+			*errors = append(*errors, err.Error())
+			return data, false
+		}
+		return converted, true
+	}
+}
+
+// indexes returns the sorted list indexes found in variables named after
+// varName
+func (e envSource) indexes(env map[string]string, varName string) []int {
+	prefix := varName + e.separator
+	set := make(map[int]struct{})
+
+	for name := range env {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		index := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(index, e.separator); i >= 0 {
+			index = index[:i]
+		}
+
+		if n, err := strconv.Atoi(index); err == nil && n >= 0 {
+			set[n] = struct{}{}
+		}
+	}
+
+	indexes := make([]int, 0, len(set))
+	for index := range set {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+// convertScalar converts the textual value to the raw value decode expects
+// for the kind of typ, values for interface fields are typed as yaml
+// scalars.
+func convertScalar(name, value string, typ reflect.Type) (interface{}, error) {
+	var converted interface{}
+	var err error
+
+	// Durations such as 5s are parsed by the decoding
+	if typ == durationType {
+		return value, nil
+	}
+
+	switch getTypeKind(typ) {
+	case reflect.Bool:
+		converted, err = strconv.ParseBool(value)
+	case reflect.Int:
+		converted, err = strconv.Atoi(value)
+	case reflect.Uint:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, 64)
+		converted = uint(u)
+	case reflect.Float64:
+		converted, err = strconv.ParseFloat(value, 64)
+	case reflect.String:
+		converted = value
+	default:
+		err = yaml.Unmarshal([]byte(value), &converted)
+		if err == nil {
+			converted, err = normalizeTree(converted)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(
+			"'%s' expected type '%s', got unconvertible value: '%s'",
+			name, typ, value)
+	}
+
+	return converted, nil
+}
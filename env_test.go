@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type EnvServer struct {
+	Host string  `mirror:"host"`
+	Port int     `mirror:"port"`
+	TLS  bool    `mirror:"tls"`
+	Rate float64 `mirror:"rate,env=ENVTEST_RATE"`
+}
+
+type EnvPlugin struct {
+	Name string `mirror:"name"`
+	Size uint   `mirror:"size"`
+}
+
+type EnvConfig struct {
+	Server  EnvServer   `mirror:"server"`
+	Plugins []EnvPlugin `mirror:"plugins"`
+}
+
+func setenv(t *testing.T, env map[string]string) {
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+
+	t.Cleanup(func() {
+		for key := range env {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestEnvSource(t *testing.T) {
+
+	setenv(t, map[string]string{
+		"ENVTEST_SERVER_PORT":      "9090",
+		"ENVTEST_SERVER_TLS":       "true",
+		"ENVTEST_RATE":             "0.5",
+		"ENVTEST_PLUGINS_0_SIZE":   "10",
+		"ENVTEST_PLUGINS_1_NAME":   "second",
+		"ENVTEST_PLUGINS_1_SIZE":   "20",
+		"ENVTEST_SERVER_NOT_FIELD": "ignored",
+	})
+
+	base := []byte(`
+server:
+  host: localhost
+  port: 8080
+  tls: false
+  rate: 1.0
+plugins:
+  - name: first
+    size: 1
+`)
+
+	want := EnvConfig{
+		Server: EnvServer{Host: "localhost", Port: 9090, TLS: true, Rate: 0.5},
+		Plugins: []EnvPlugin{
+			{Name: "first", Size: 10},
+			{Name: "second", Size: 20},
+		},
+	}
+
+	var config EnvConfig
+	err := Load(&config, YamlSource(base), EnvSource("ENVTEST", "_"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+}
+
+func TestEnvSourceErrors(t *testing.T) {
+
+	setenv(t, map[string]string{
+		"ENVERR__SERVER__PORT":            "http",
+		"ENVERR__SERVER__TLS":             "maybe",
+		"ENVERR__PLUGINS__0__NAME":        "first",
+		"ENVERR__PLUGINS__50000000__NAME": "typo",
+	})
+
+	wanterr := &Error{
+		Errors: []string{
+			"'ENVERR__SERVER__PORT' expected type 'int', got unconvertible value: 'http'",
+			"'ENVERR__SERVER__TLS' expected type 'bool', got unconvertible value: 'maybe'",
+			"'ENVERR__PLUGINS__50000000' index out of range, list has 1 items",
+		},
+	}
+
+	_, err := EnvSource("ENVERR", "__").Apply(map[string]interface{}{}, reflect.TypeOf(EnvConfig{}))
+
+	assert.Error(t, err)
+	assert.Equal(t, wanterr, err)
+}
@@ -0,0 +1,202 @@
+package mirror
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// flagValue is a flag.Value holding the raw value of a configuration field
+type flagValue struct {
+	keys  []string
+	typ   reflect.Type
+	text  string
+	value interface{}
+	isSet bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.text
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return getTypeKind(f.typ) == reflect.Bool
+}
+
+func (f *flagValue) Set(text string) error {
+	if f.typ.Kind() != reflect.Slice {
+		value, err := convertFlag(text, f.typ)
+		if err != nil {
+			return err
+		}
+
+		f.text, f.value, f.isSet = text, value, true
+		return nil
+	}
+
+	// Slices accept comma separated values and repeated flags
+	values := []interface{}{}
+	if f.isSet {
+		values = f.value.([]interface{})
+	}
+
+	for _, item := range strings.Split(text, ",") {
+		value, err := convertFlag(item, f.typ.Elem())
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+
+	if f.isSet {
+		text = f.text + "," + text
+	}
+
+	f.text, f.value, f.isSet = text, values, true
+	return nil
+}
+
+// convertFlag converts the text of a flag to the raw value of a field
+func convertFlag(text string, typ reflect.Type) (interface{}, error) {
+	if typ == durationType {
+		return time.ParseDuration(text)
+	}
+
+	value, err := convertScalar("", text, typ)
+	if err != nil {
+		return nil, fmt.Errorf("expected type '%s', got unconvertible value: '%s'", typ, text)
+	}
+
+	return value, nil
+}
+
+// flagSource is a source applying the flags set on the command line
+type flagSource struct {
+	fs    *flag.FlagSet
+	flags map[string]*flagValue
+}
+
+// BindFlags defines a flag in fs for every bool, integer, float, string,
+// duration and slice field of the structure config, named after the path of
+// its `mirror` tags (--server.port). The `desc=` tag option is used as usage
+// text and the current values of config as defaults. Once fs is parsed the
+// returned source applies the flags found on the command line, it is meant
+// to be the last source passed to Load so that flags take precedence.
+func BindFlags(fs *flag.FlagSet, config interface{}) (Source, error) {
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("bind flags: config must be a non nil pointer to struct")
+	}
+
+	source := flagSource{fs, make(map[string]*flagValue)}
+	if err := source.bind(nil, val.Elem()); err != nil {
+		return nil, fmt.Errorf("bind flags: %s", err)
+	}
+
+	return source, nil
+}
+
+// bind defines the flags of the fields of the struct val
+func (f flagSource) bind(keys []string, val reflect.Value) error {
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldVal := val.Field(i)
+
+		tag, err := parseTag(field)
+		if err != nil {
+			return err
+		}
+
+		if tag.Name == "" || tag.Dynamic != "" || tag.Union != "" {
+			continue
+		}
+
+		fieldKeys := append(append([]string(nil), keys...), tag.Name)
+		name := strings.Join(fieldKeys, ".")
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			if fieldVal.IsNil() {
+				fieldVal = reflect.Zero(fieldType)
+			} else {
+				fieldVal = fieldVal.Elem()
+			}
+		}
+
+		switch getTypeKind(fieldType) {
+		case reflect.Struct:
+			if err := f.bind(fieldKeys, fieldVal); err != nil {
+				return err
+			}
+			continue
+		case reflect.Slice:
+			switch getTypeKind(fieldType.Elem()) {
+			case reflect.Bool, reflect.Int, reflect.Uint, reflect.Float64, reflect.String:
+			default:
+				continue
+			}
+		case reflect.Bool, reflect.Int, reflect.Uint, reflect.Float64, reflect.String:
+		default:
+			continue
+		}
+
+		value := &flagValue{keys: fieldKeys, typ: fieldType, text: flagDefault(fieldVal)}
+		f.flags[name] = value
+		f.fs.Var(value, name, tag.Desc)
+	}
+
+	return nil
+}
+
+// flagDefault formats the current value of a field as flag default
+func flagDefault(val reflect.Value) string {
+	if val.Kind() != reflect.Slice {
+		return fmt.Sprint(val.Interface())
+	}
+
+	items := make([]string, val.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(val.Index(i).Interface())
+	}
+	return strings.Join(items, ",")
+}
+
+func (f flagSource) Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		value, ok := f.flags[fl.Name]
+		if !ok || !value.isSet {
+			return
+		}
+
+		raw = setTreeValue(raw, value.keys, value.value).(map[string]interface{})
+	})
+
+	return raw, nil
+}
+
+// setTreeValue returns a copy of the tree data with value set at the map
+// key path keys
+func setTreeValue(data interface{}, keys []string, value interface{}) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+
+	dataMap, _ := data.(map[string]interface{})
+	merged := make(map[string]interface{}, len(dataMap)+1)
+	for key, value := range dataMap {
+		merged[key] = value
+	}
+
+	merged[keys[0]] = setTreeValue(merged[keys[0]], keys[1:], value)
+	return merged
+}
@@ -0,0 +1,91 @@
+package mirror
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type FlagsServer struct {
+	Port    int           `mirror:"port,desc=listen port, 0 for random"`
+	TLS     bool          `mirror:"tls"`
+	Timeout time.Duration `mirror:"timeout"`
+}
+
+type FlagsConfig struct {
+	Name    string      `mirror:"name"`
+	Ratio   float64     `mirror:"ratio"`
+	Workers uint        `mirror:"workers,optional"`
+	Tags    []string    `mirror:"tags"`
+	Server  FlagsServer `mirror:"server"`
+}
+
+func TestBindFlags(t *testing.T) {
+
+	base := []byte(`
+name: base
+ratio: 0.5
+tags: [a]
+server:
+  port: 8080
+  tls: false
+  timeout: 1m30s
+`)
+
+	want := FlagsConfig{
+		Name:    "base",
+		Ratio:   0.5,
+		Workers: 4,
+		Tags:    []string{"b", "c", "d"},
+		Server:  FlagsServer{Port: 9090, TLS: true, Timeout: 5 * time.Second},
+	}
+
+	config := FlagsConfig{Server: FlagsServer{Port: 80}}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	source, err := BindFlags(fs, &config)
+	assert.NoError(t, err)
+
+	port := fs.Lookup("server.port")
+	assert.Equal(t, "listen port, 0 for random", port.Usage)
+	assert.Equal(t, "80", port.DefValue)
+
+	err = fs.Parse([]string{
+		"--server.port=9090",
+		"--server.tls",
+		"--server.timeout=5s",
+		"--workers=4",
+		"--tags=b,c",
+		"--tags=d",
+	})
+	assert.NoError(t, err)
+
+	err = Load(&config, YamlSource(base), source)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	err = Load(&config, YamlSource(base), SetSource("server.timeout=2m"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, config.Server.Timeout)
+}
+
+func TestBindFlagsErrors(t *testing.T) {
+
+	var config FlagsConfig
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	_, err := BindFlags(fs, &config)
+	assert.NoError(t, err)
+
+	err = fs.Parse([]string{"--server.port=http"})
+	assert.Error(t, err)
+
+	_, err = BindFlags(fs, config)
+	assert.Error(t, err)
+}
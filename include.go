@@ -0,0 +1,274 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// defaultIncludeDepth is the default maximum nesting of included files
+const defaultIncludeDepth = 16
+
+// rootDocument names the document passed to the unmarshal functions in
+// include errors
+const rootDocument = "document"
+
+// includeResolver resolves the includes of a document and of every file it
+// includes
+type includeResolver struct {
+	fsys      fs.FS
+	maxDepth  int
+	nodeLimit int
+	// stack holds the files being included, for cycle detection
+	stack []string
+}
+
+func newIncludeResolver(o *options) *includeResolver {
+	return &includeResolver{fsys: o.fsys, maxDepth: o.includeDepth, nodeLimit: o.nodeLimit}
+}
+
+// resolveNode replaces every `!include` tagged node below node, read from
+// file inside dir, by the included content. Included yaml nodes are put in
+// place as parsed, so that their scalars resolve as if written inline.
+func (r *includeResolver) resolveNode(file, dir string, node *yaml.Node) error {
+	if node.Tag == "!include" {
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s:%d:%d: !include expects a file name", file, node.Line, node.Column)
+		}
+
+		included, err := r.includeNode(dir, node.Value)
+		if err != nil {
+			return fmt.Errorf("%s:%d:%d: include '%s': %s", file, node.Line, node.Column, node.Value, err)
+		}
+
+		*node = *included
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := r.resolveNode(file, dir, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveJson returns the json tree, read from file inside dir, with every
+// {"$ref": "..."} object replaced by the referenced content
+func (r *includeResolver) resolveJson(file, dir string, tree interface{}, pointer string) (interface{}, error) {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		if ref, ok := t["$ref"]; ok && len(t) == 1 {
+			refString, ok := ref.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: $ref at '%s' expects a string, got '%v'", file, pointer, ref)
+			}
+
+			value, err := r.include(dir, refString)
+			if err != nil {
+				return nil, fmt.Errorf("%s: $ref '%s' at '%s': %s", file, refString, pointer, err)
+			}
+			return value, nil
+		}
+
+		resolved := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			escaped := strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+
+			resolvedValue, err := r.resolveJson(file, dir, value, pointer+"/"+escaped)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = resolvedValue
+		}
+		return resolved, nil
+
+	case []interface{}:
+		resolved := make([]interface{}, len(t))
+		for i, value := range t {
+			resolvedValue, err := r.resolveJson(file, dir, value, pointer+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = resolvedValue
+		}
+		return resolved, nil
+
+	default:
+		return tree, nil
+	}
+}
+
+// include returns the tree of the file referenced by ref, as includeNode
+func (r *includeResolver) include(dir, ref string) (interface{}, error) {
+	node, err := r.includeNode(dir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeYamlNode(node, r.nodeLimit)
+}
+
+// includeNode reads the file referenced by ref, relative to dir and
+// optionally followed by a json pointer (file.yaml#/section), and returns
+// its yaml node
+func (r *includeResolver) includeNode(dir, ref string) (*yaml.Node, error) {
+	file, pointer := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		file, pointer = ref[:i], ref[i+1:]
+	}
+
+	if file == "" {
+		return nil, fmt.Errorf("missing file name")
+	}
+
+	filePath := path.Join(dir, file)
+
+	for _, included := range r.stack {
+		if included == filePath {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(r.stack, filePath), " -> "))
+		}
+	}
+
+	if len(r.stack) >= r.maxDepth {
+		return nil, fmt.Errorf("include depth limit of %d exceeded", r.maxDepth)
+	}
+
+	data, err := fs.ReadFile(r.fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	r.stack = append(r.stack, filePath)
+	defer func() {
+		r.stack = r.stack[:len(r.stack)-1]
+	}()
+
+	if strings.ToLower(path.Ext(filePath)) == ".json" {
+		var tree interface{}
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("%s: %s", filePath, err)
+		}
+
+		tree, err = r.resolveJson(filePath, path.Dir(filePath), tree, "")
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := resolvePointer(tree, pointer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filePath, err)
+		}
+
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return nil, fmt.Errorf("%s: %s", filePath, err)
+		}
+		return &node, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	if err := r.resolveNode(filePath, path.Dir(filePath), &node); err != nil {
+		return nil, err
+	}
+
+	value, err := resolveNodePointer(&node, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	return value, nil
+}
+
+// resolveNodePointer returns the node of the yaml document node at the json
+// pointer, an empty document gives a null node
+func resolveNodePointer(node *yaml.Node, pointer string) (*yaml.Node, error) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == 0 || node.Kind == yaml.DocumentNode {
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+
+	if pointer == "" {
+		return node, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer '%s'", pointer)
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+
+		var child *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					child = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(node.Content) {
+				child = node.Content[i]
+			}
+		}
+
+		if child == nil {
+			return nil, fmt.Errorf("pointer '%s' not found", pointer)
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// resolvePointer returns the value of tree at the json pointer
+func resolvePointer(tree interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return tree, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer '%s'", pointer)
+	}
+
+	value := tree
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("pointer '%s' not found", pointer)
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("pointer '%s' not found", pointer)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("pointer '%s' not found", pointer)
+		}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,137 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+type IncludeTLS struct {
+	Cert string `mirror:"cert"`
+	Key  string `mirror:"key"`
+}
+
+type IncludeConfig struct {
+	Name   string     `mirror:"name"`
+	TLS    IncludeTLS `mirror:"tls"`
+	Labels []string   `mirror:"labels"`
+}
+
+func TestIncludes(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"conf/tls.yaml":         {Data: []byte("cert: a.pem\nkey: !include keys/key.yaml#/name\n")},
+		"conf/keys/key.yaml":    {Data: []byte("name: a.key\n")},
+		"conf/labels.json":      {Data: []byte(`{"all": {"$ref": "more/labels.json#/list"}}`)},
+		"conf/more/labels.json": {Data: []byte(`{"list": ["a", "b/c"]}`)},
+	}
+
+	want := IncludeConfig{
+		Name:   "included",
+		TLS:    IncludeTLS{Cert: "a.pem", Key: "a.key"},
+		Labels: []string{"a", "b/c"},
+	}
+
+	yamlContent := []byte(`
+name: included
+tls: !include tls.yaml
+labels: !include labels.json#/all
+`)
+
+	var config IncludeConfig
+	err := UnmarshalYaml(yamlContent, &config, WithIncludes(fsys, "conf"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	jsonContent := []byte(`{
+		"name": "included",
+		"tls": {"$ref": "tls.yaml"},
+		"labels": {"$ref": "labels.json#/all"}
+	}`)
+
+	config = IncludeConfig{}
+	err = UnmarshalJson(jsonContent, &config, WithIncludes(fsys, "conf"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+}
+
+func TestIncludesYamlScalars(t *testing.T) {
+
+	type Sub struct {
+		On   bool   `mirror:"on"`
+		Name string `mirror:"name"`
+	}
+
+	type Config struct {
+		Sub  Sub  `mirror:"sub"`
+		Flag bool `mirror:"flag"`
+	}
+
+	fsys := fstest.MapFS{
+		"sub.yaml": {Data: []byte("sub:\n  on: yes\n  name: no\nflag: &flag off\nref: *flag\n")},
+	}
+
+	// Included scalars resolve as if written inline, aliases included
+	var config Config
+	err := UnmarshalYaml([]byte("sub: !include sub.yaml#/sub\nflag: !include sub.yaml#/ref\n"), &config, WithIncludes(fsys, "."))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Sub: Sub{On: true, Name: "no"}, Flag: false}, config)
+}
+
+func TestIncludesErrors(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"a.yaml":  {Data: []byte("a: !include b.yaml\n")},
+		"b.yaml":  {Data: []byte("b: !include a.yaml\n")},
+		"c.json":  {Data: []byte(`{"c": {"$ref": "d.json"}}`)},
+		"d.json":  {Data: []byte(`{"d": {"$ref": "c.json"}}`)},
+		"ok.yaml": {Data: []byte("ok: true\n")},
+	}
+
+	tests_ok := []struct {
+		name      string
+		unmarshal func([]byte, interface{}, ...Option) error
+		data      string
+		opts      []Option
+		wanterr   string
+	}{
+		{
+			"yaml cycle", UnmarshalYaml, "tls: !include a.yaml",
+			[]Option{WithIncludes(fsys, ".")},
+			"unmarshal yaml: document:1:6: include 'a.yaml': a.yaml:1:4: include 'b.yaml': b.yaml:1:4: include 'a.yaml': include cycle: a.yaml -> b.yaml -> a.yaml",
+		},
+		{
+			"yaml missing", UnmarshalYaml, "name: x\ntls: !include missing.yaml",
+			[]Option{WithIncludes(fsys, ".")},
+			"unmarshal yaml: document:2:6: include 'missing.yaml': open missing.yaml: file does not exist",
+		},
+		{
+			"yaml pointer", UnmarshalYaml, "tls: !include ok.yaml#/ko",
+			[]Option{WithIncludes(fsys, ".")},
+			"unmarshal yaml: document:1:6: include 'ok.yaml#/ko': ok.yaml: pointer '/ko' not found",
+		},
+		{
+			"yaml depth", UnmarshalYaml, "tls: !include a.yaml",
+			[]Option{WithIncludes(fsys, "."), WithIncludeDepth(1)},
+			"unmarshal yaml: document:1:6: include 'a.yaml': a.yaml:1:4: include 'b.yaml': include depth limit of 1 exceeded",
+		},
+		{
+			"json cycle", UnmarshalJson, `{"tls": {"$ref": "c.json"}}`,
+			[]Option{WithIncludes(fsys, ".")},
+			"unmarshal json: document: $ref 'c.json' at '/tls': c.json: $ref 'd.json' at '/c': d.json: $ref 'c.json' at '/d': include cycle: c.json -> d.json -> c.json",
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+
+			var config IncludeConfig
+			err := tt.unmarshal([]byte(tt.data), &config, tt.opts...)
+
+			assert.EqualError(t, err, tt.wanterr)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+)
+
+// Source is a layer of configuration combined by Load
+type Source interface {
+	// Apply applies the source on top of raw, the tree built by the
+	// previous sources for a configuration structure of type typ
+	Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error)
+}
+
+// Load builds the configuration structure from several sources applied in
+// order, each one overriding the previous ones, and decodes the result into
+// config. The strict missing and unused key checks run on the merged tree.
+func Load(config interface{}, sources ...Source) error {
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("load: config must be a non nil pointer")
+	}
+
+	var err error
+	raw := make(map[string]interface{})
+	typ := val.Type().Elem()
+
+	for i, source := range sources {
+		raw, err = source.Apply(raw, typ)
+		if err != nil {
+			return fmt.Errorf("source %d: %s", i, err)
+		}
+	}
+
+	err = decodeMapLevels(raw, config)
+	if err != nil {
+		return fmt.Errorf("decode map: %s", err)
+	}
+
+	return nil
+}
+
+// mapSource is a source merging an already parsed tree
+type mapSource map[string]interface{}
+
+func (m mapSource) Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error) {
+	merged, err := mergeTree("", raw, map[string]interface{}(m), typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return merged.(map[string]interface{}), nil
+}
+
+// MapSource returns a source deep merging m over the previous sources
+func MapSource(m map[string]interface{}) Source {
+	return mapSource(m)
+}
+
+// parserSource is a source merging a document once parsed
+type parserSource struct {
+	data  []byte
+	parse func([]byte, *options) (map[string]interface{}, error)
+	opts  []Option
+}
+
+func (p parserSource) Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error) {
+	rawmap, err := p.parse(p.data, newOptions(p.opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return mapSource(rawmap).Apply(raw, typ)
+}
+
+// YamlSource returns a source deep merging the yaml document data over the
+// previous sources
+func YamlSource(data []byte, opts ...Option) Source {
+	return CodecSource(yamlCodec{}, data, opts...)
+}
+
+// JsonSource returns a source deep merging the json document data over the
+// previous sources
+func JsonSource(data []byte, opts ...Option) Source {
+	return CodecSource(jsonCodec{}, data, opts...)
+}
+
+// parseYaml parses a yaml document into a raw tree with string keys
+func parseYaml(data []byte, o *options) (map[string]interface{}, error) {
+	var node yaml.Node
+
+	err := yaml.Unmarshal(data, &node)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %s", err)
+	}
+
+	if o.fsys != nil {
+		err = newIncludeResolver(o).resolveNode(rootDocument, o.dir, &node)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal yaml: %s", err)
+		}
+	}
+
+	tree, err := decodeYamlNode(&node, o.nodeLimit)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %s", err)
+	}
+
+	if tree == nil {
+		return make(map[string]interface{}), nil
+	}
+
+	rawmap, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unmarshal yaml: document must be a mapping, got '%T'", tree)
+	}
+
+	return rawmap, nil
+}
+
+// parseJson parses a json document into a raw tree
+func parseJson(data []byte, o *options) (map[string]interface{}, error) {
+	rawmap := make(map[string]interface{})
+
+	err := json.Unmarshal(data, &rawmap)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal json: %s", err)
+	}
+
+	if o.fsys == nil {
+		return rawmap, nil
+	}
+
+	resolved, err := newIncludeResolver(o).resolveJson(rootDocument, o.dir, rawmap, "")
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal json: %s", err)
+	}
+
+	resolvedMap, ok := resolved.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unmarshal json: %s: $ref must resolve to an object", rootDocument)
+	}
+
+	return resolvedMap, nil
+}
+
+// normalizeTree converts the map[interface{}]interface{} produced by the
+// yaml parser into map[string]interface{}, and the []map[string]interface{}
+// produced by the toml parser into []interface{}, so that trees coming from
+// different formats can be merged.
+func normalizeTree(data interface{}) (interface{}, error) {
+	switch d := data.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(d))
+		for key, value := range d {
+			skey, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("non string key '%v'", key)
+			}
+
+			nvalue, err := normalizeTree(value)
+			if err != nil {
+				return nil, err
+			}
+			m[skey] = nvalue
+		}
+		return m, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(d))
+		for key, value := range d {
+			nvalue, err := normalizeTree(value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nvalue
+		}
+		return m, nil
+	case []map[string]interface{}:
+		s := make([]interface{}, len(d))
+		for i, value := range d {
+			nvalue, err := normalizeTree(value)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = nvalue
+		}
+		return s, nil
+	case []interface{}:
+		s := make([]interface{}, len(d))
+		for i, value := range d {
+			nvalue, err := normalizeTree(value)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = nvalue
+		}
+		return s, nil
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,121 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type LoadServer struct {
+	Host string `mirror:"host"`
+	Port int    `mirror:"port"`
+}
+
+type LoadConfig struct {
+	Name    string     `mirror:"name"`
+	Server  LoadServer `mirror:"server"`
+	Tags    []string   `mirror:"tags"`
+	Backend DynTyp     `mirror:"backend,dynamic=type"`
+}
+
+func TestLoadLayers(t *testing.T) {
+
+	base := []byte(`
+name: base
+server:
+  host: localhost
+  port: 8080
+tags: [a, b]
+backend:
+  type: int
+  value: 1
+`)
+
+	overlay := []byte(`
+server:
+  port: 9090
+tags: [c]
+`)
+
+	override := map[string]interface{}{
+		"name": "override",
+	}
+
+	want := LoadConfig{
+		Name:    "override",
+		Server:  LoadServer{Host: "localhost", Port: 9090},
+		Tags:    []string{"c"},
+		Backend: DynTyp{Type: "int", Value: 1},
+	}
+
+	var config LoadConfig
+	err := Load(&config, YamlSource(base), YamlSource(overlay), MapSource(override))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+}
+
+func TestLoadDynamicReplaced(t *testing.T) {
+
+	base := map[string]interface{}{
+		"backend": map[string]interface{}{
+			"type":  "int",
+			"value": 1,
+			"extra": true,
+		},
+	}
+
+	overlay := map[string]interface{}{
+		"backend": map[string]interface{}{
+			"type":  "other",
+			"value": 2,
+		},
+	}
+
+	merged, err := MapSource(overlay).Apply(base, reflect.TypeOf(LoadConfig{}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, overlay, merged)
+}
+
+func TestLoadErrors(t *testing.T) {
+
+	base := []byte(`{"name": "base", "unknown": 1}`)
+	broken := []byte(`name: [`)
+
+	var config LoadConfig
+
+	err := Load(&config, JsonSource(base), YamlSource(broken))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "source 1: unmarshal yaml")
+
+	err = Load(&config, JsonSource(base))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "detected unused keys: unknown")
+
+	err = Load(config)
+	assert.Error(t, err)
+}
+
+func TestLoadDynamicParentReplaced(t *testing.T) {
+
+	type Object struct {
+		Kind string  `mirror:"kind"`
+		Body SpecTyp `mirror:"body,dynamic=../kind"`
+	}
+
+	base := map[string]interface{}{
+		"kind": "Deployment",
+		"body": map[string]interface{}{"spec": map[string]interface{}{"replicas": 3}},
+	}
+
+	overlay := map[string]interface{}{
+		"kind": "Service",
+		"body": map[string]interface{}{"spec": map[string]interface{}{"port": 80}},
+	}
+
+	merged, err := MapSource(overlay).Apply(base, reflect.TypeOf(Object{}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, overlay, merged)
+}
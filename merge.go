@@ -0,0 +1,234 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Merge strategies selected through the `merge=` tag option
+const (
+	mergeDefault = ""
+	mergeReplace = "replace"
+	mergeAppend  = "append"
+	mergeDeep    = "deep"
+	mergeKey     = "key:"
+)
+
+// validMergeStrategy reports if strategy is a supported `merge=` option
+func validMergeStrategy(strategy string) bool {
+	switch {
+	case strategy == mergeReplace, strategy == mergeAppend, strategy == mergeDeep:
+		return true
+	case strings.HasPrefix(strategy, mergeKey):
+		return len(strategy) > len(mergeKey)
+	default:
+		return false
+	}
+}
+
+// mergeTree merges overlay over base: maps are merged key by key, any other
+// value is replaced. typ is the type the tree decodes to, when known, and
+// is used to honour the `merge=` tag options and to replace dynamic
+// subtrees whose selector changes.
+func mergeTree(name string, base, overlay interface{}, typ reflect.Type) (interface{}, error) {
+	baseMap, baseOk := base.(map[string]interface{})
+	overlayMap, overlayOk := overlay.(map[string]interface{})
+	if !baseOk || !overlayOk {
+		return overlay, nil
+	}
+
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	merged := make(map[string]interface{}, len(baseMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+
+	for key, value := range overlayMap {
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
+
+		field, tag, ok := structFieldByTag(typ, key)
+		if !ok {
+			mergedValue, err := mergeTree(fieldName, merged[key], value, nil)
+			if err != nil {
+				return nil, err
+			}
+			merged[key] = mergedValue
+			continue
+		}
+
+		if tag.Union == unionExternal && unionTypeChanged(merged[key], value) {
+			merged[key] = value
+			continue
+		}
+
+		if tag.Dynamic != "" && tag.Union == "" && dynamicSelectorChanged(baseMap, overlayMap, merged[key], value, tag.Dynamic) {
+			merged[key] = value
+			continue
+		}
+
+		mergedValue, err := mergeField(fieldName, merged[key], value, field.Type, tag.Merge)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = mergedValue
+	}
+
+	return merged, nil
+}
+
+// mergeField merges the overlay value of a struct field over its base value
+// following the field merge strategy.
+func mergeField(name string, base, overlay interface{}, typ reflect.Type, strategy string) (interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if strategy == mergeDefault {
+		return mergeTree(name, base, overlay, typ)
+	}
+
+	if strategy == mergeReplace {
+		return overlay, nil
+	}
+
+	baseSlice, baseOk := base.([]interface{})
+	overlaySlice, overlayOk := overlay.([]interface{})
+	if !baseOk || !overlayOk {
+		if strategy == mergeDeep {
+			return mergeTree(name, base, overlay, typ)
+		}
+		return overlay, nil
+	}
+
+	var elemType reflect.Type
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		elemType = typ.Elem()
+	}
+
+	switch {
+	case strategy == mergeAppend:
+		merged := make([]interface{}, 0, len(baseSlice)+len(overlaySlice))
+		merged = append(merged, baseSlice...)
+		return append(merged, overlaySlice...), nil
+
+	case strategy == mergeDeep:
+		merged := append([]interface{}(nil), baseSlice...)
+		for i, item := range overlaySlice {
+			if i >= len(merged) {
+				merged = append(merged, item)
+				continue
+			}
+
+			mergedItem, err := mergeTree(name+"["+strconv.Itoa(i)+"]", merged[i], item, elemType)
+			if err != nil {
+				return nil, err
+			}
+			merged[i] = mergedItem
+		}
+		return merged, nil
+
+	default:
+		return mergeSliceByKey(name, baseSlice, overlaySlice, elemType, strings.TrimPrefix(strategy, mergeKey))
+	}
+}
+
+// mergeSliceByKey merges the overlay items into the base items sharing the
+// same value for key, overlay items with a new value are appended.
+func mergeSliceByKey(name string, base, overlay []interface{}, elemType reflect.Type, key string) (interface{}, error) {
+	merged := append([]interface{}(nil), base...)
+
+	for i, item := range overlay {
+		itemName := name + "[" + strconv.Itoa(i) + "]"
+
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' expected a map to merge by key '%s', got '%v'", itemName, key, item)
+		}
+
+		keyValue, ok := itemMap[key]
+		if !ok {
+			return nil, fmt.Errorf("'%s' merge key '%s' not found", itemName, key)
+		}
+
+		found := false
+		for j, baseItem := range merged {
+			baseMap, ok := baseItem.(map[string]interface{})
+			if !ok || !reflect.DeepEqual(plainTree(baseMap[key]), plainTree(keyValue)) {
+				continue
+			}
+
+			mergedItem, err := mergeTree(itemName, baseMap, itemMap, elemType)
+			if err != nil {
+				return nil, err
+			}
+			merged[j] = mergedItem
+			found = true
+			break
+		}
+
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+
+	return merged, nil
+}
+
+// dynamicSelectorChanged reports if the overlay of a dynamic subtree sets
+// a selector different from the base one. Selectors looked up from the
+// parent are read from the parent maps baseParent and overlayParent.
+func dynamicSelectorChanged(baseParent, overlayParent map[string]interface{}, base, overlay interface{}, selector string) bool {
+	if fromParent, _ := selectorPath(selector); !fromParent {
+		_, baseOk := base.(map[string]interface{})
+		_, overlayOk := overlay.(map[string]interface{})
+		if !baseOk || !overlayOk {
+			return false
+		}
+	}
+
+	overlayValue := lookupSelector(reflect.ValueOf(overlay), reflect.ValueOf(overlayParent), selector)
+	if !overlayValue.IsValid() {
+		return false
+	}
+	overlaySelector := overlayValue.Interface()
+
+	var baseSelector interface{}
+	if baseValue := lookupSelector(reflect.ValueOf(base), reflect.ValueOf(baseParent), selector); baseValue.IsValid() {
+		baseSelector = baseValue.Interface()
+	}
+
+	// Compare the canonical forms, 2 and "2" select the same type
+	baseString, baseOk := selectorString(baseSelector)
+	overlayString, overlayOk := selectorString(overlaySelector)
+	if baseOk && overlayOk {
+		return baseString != overlayString
+	}
+
+	return !reflect.DeepEqual(plainTree(baseSelector), plainTree(overlaySelector))
+}
+
+// unionTypeChanged reports if the overlay of an externally tagged union
+// names a type different from the base one
+func unionTypeChanged(base, overlay interface{}) bool {
+	baseMap, baseOk := base.(map[string]interface{})
+	overlayMap, overlayOk := overlay.(map[string]interface{})
+	if !baseOk || !overlayOk {
+		return false
+	}
+
+	for key := range overlayMap {
+		if _, ok := baseMap[key]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
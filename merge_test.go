@@ -0,0 +1,127 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type MergePlugin struct {
+	Name    string `mirror:"name"`
+	Enabled bool   `mirror:"enabled"`
+}
+
+type MergeConfig struct {
+	Plugins  []MergePlugin `mirror:"plugins,merge=key:name"`
+	Tags     []string      `mirror:"tags,merge=append"`
+	Ports    []int         `mirror:"ports,merge=deep"`
+	Labels   interface{}   `mirror:"labels,merge=replace"`
+	Defaults []string      `mirror:"defaults"`
+}
+
+func TestMergeStrategies(t *testing.T) {
+	t.Parallel()
+
+	tests_ok := []struct {
+		name    string
+		base    map[string]interface{}
+		overlay map[string]interface{}
+		want    map[string]interface{}
+		err     bool
+	}{
+		{
+			"merge key",
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": "a", "enabled": false},
+				map[string]interface{}{"name": "b", "enabled": false},
+			}},
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": "b", "enabled": true},
+				map[string]interface{}{"name": "c", "enabled": true},
+			}},
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": "a", "enabled": false},
+				map[string]interface{}{"name": "b", "enabled": true},
+				map[string]interface{}{"name": "c", "enabled": true},
+			}},
+			false,
+		},
+		{
+			"merge key yaml word",
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": yamlBoolWord("on"), "enabled": false},
+			}},
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": "on", "enabled": true},
+			}},
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"name": "on", "enabled": true},
+			}},
+			false,
+		},
+		{
+			"merge key missing",
+			map[string]interface{}{"plugins": []interface{}{}},
+			map[string]interface{}{"plugins": []interface{}{
+				map[string]interface{}{"enabled": true},
+			}},
+			nil,
+			true,
+		},
+		{
+			"merge append",
+			map[string]interface{}{"tags": []interface{}{"a"}},
+			map[string]interface{}{"tags": []interface{}{"b"}},
+			map[string]interface{}{"tags": []interface{}{"a", "b"}},
+			false,
+		},
+		{
+			"merge deep",
+			map[string]interface{}{"ports": []interface{}{1, 2, 3}},
+			map[string]interface{}{"ports": []interface{}{4}},
+			map[string]interface{}{"ports": []interface{}{4, 2, 3}},
+			false,
+		},
+		{
+			"merge replace",
+			map[string]interface{}{"labels": map[string]interface{}{"a": "1"}},
+			map[string]interface{}{"labels": map[string]interface{}{"b": "2"}},
+			map[string]interface{}{"labels": map[string]interface{}{"b": "2"}},
+			false,
+		},
+		{
+			"merge default",
+			map[string]interface{}{"defaults": []interface{}{"a"}},
+			map[string]interface{}{"defaults": []interface{}{"b"}},
+			map[string]interface{}{"defaults": []interface{}{"b"}},
+			false,
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			merged, err := MapSource(tt.overlay).Apply(tt.base, reflect.TypeOf(MergeConfig{}))
+
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, merged)
+			}
+		})
+	}
+}
+
+func TestMergeInvalidStrategy(t *testing.T) {
+
+	type Config struct {
+		Tags []string `mirror:"tags,merge=prepend"`
+	}
+
+	var config Config
+	err := Load(&config, MapSource(map[string]interface{}{"tags": []interface{}{}}))
+
+	assert.Error(t, err)
+}
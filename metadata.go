@@ -0,0 +1,245 @@
+package mirror
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Metadata reports how a document was mirrored into the configuration
+// structure, key paths are dot separated tag names with slice indexes,
+// such as plugins[0].config.valueint
+type Metadata struct {
+	// Keys lists the key paths mirrored into a field
+	Keys []string
+	// Unused lists the key paths not matching any field
+	Unused []string
+	// Unset lists the fields whose key is absent, left to their zero value
+	Unset []string
+	// Defaults lists the fields whose key is absent, keeping the value set
+	// before decoding
+	Defaults []string
+	// DynamicTypes maps the key path of every dynamic field to the value of
+	// its selector
+	DynamicTypes map[string]string
+}
+
+// WithMetadata fills md with the keys used, unused and unset by the
+// decoding, which is filled even when the decoding fails
+func WithMetadata(md *Metadata) Option {
+	return func(o *options) {
+		o.metadata = md
+	}
+}
+
+// recordMetadata fills the metadata requested by the options from the raw
+// tree decoded into config
+func (o *options) recordMetadata(raw interface{}, config interface{}) {
+	if o.metadata == nil {
+		return
+	}
+
+	md := Metadata{
+		Keys:         []string{},
+		Unused:       []string{},
+		Unset:        []string{},
+		Defaults:     []string{},
+		DynamicTypes: make(map[string]string),
+	}
+
+	md.walk("", raw, reflect.ValueOf(config))
+
+	sort.Strings(md.Keys)
+	sort.Strings(md.Unused)
+	sort.Strings(md.Unset)
+	sort.Strings(md.Defaults)
+
+	*o.metadata = md
+}
+
+// walk records the keys of data decoded into val
+func (md *Metadata) walk(name string, data interface{}, val reflect.Value) {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			if val.Kind() == reflect.Interface {
+				return
+			}
+			val = reflect.Zero(val.Type().Elem())
+			continue
+		}
+		val = val.Elem()
+	}
+
+	if !val.IsValid() || val.Type() == optionalType {
+		return
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		dataMap, ok := data.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		used := make(map[string]bool, len(dataMap))
+		typ := val.Type()
+
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+
+			tag, err := parseTag(field)
+			if err != nil || tag.Name == "" {
+				continue
+			}
+
+			fieldName := joinKey(name, tag.Name)
+
+			value, ok := dataMap[tag.Name]
+			if !ok {
+				if val.Field(i).IsZero() {
+					md.Unset = append(md.Unset, fieldName)
+				} else {
+					md.Defaults = append(md.Defaults, fieldName)
+				}
+				continue
+			}
+
+			used[tag.Name] = true
+			md.Keys = append(md.Keys, fieldName)
+
+			if tag.Union == unionExternal {
+				md.walkUnion(fieldName, value, val.Field(i))
+				continue
+			}
+
+			if tag.Union == unionUntagged {
+				md.walkUntagged(fieldName, value, val.Field(i))
+				continue
+			}
+
+			if tag.Dynamic != "" {
+				md.recordDynamic(fieldName, value, dataMap, tag)
+			}
+
+			md.walk(fieldName, value, val.Field(i))
+		}
+
+		for key := range dataMap {
+			if !used[key] {
+				md.Unused = append(md.Unused, joinKey(name, key))
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		dataSlice, ok := data.([]interface{})
+		if !ok {
+			return
+		}
+
+		for i, value := range dataSlice {
+			elem := reflect.Zero(val.Type().Elem())
+			if i < val.Len() {
+				elem = val.Index(i)
+			}
+
+			md.walk(name+"["+strconv.Itoa(i)+"]", value, elem)
+		}
+	}
+}
+
+// walkUnion records the keys of the externally tagged union data decoded
+// into val, or of each of its elements
+func (md *Metadata) walkUnion(name string, data interface{}, val reflect.Value) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.Zero(val.Type().Elem())
+			continue
+		}
+		val = val.Elem()
+	}
+
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if len(d) != 1 {
+			for key := range d {
+				md.Unused = append(md.Unused, joinKey(name, key))
+			}
+			return
+		}
+
+		for typeName, payload := range d {
+			md.DynamicTypes[name] = typeName
+			md.Keys = append(md.Keys, joinKey(name, typeName))
+
+			if val.Kind() != reflect.Struct {
+				return
+			}
+
+			payloadTag, ok := unionPayload(val.Type())
+			if !ok {
+				return
+			}
+
+			field, _, _ := structFieldByTag(val.Type(), payloadTag.Name)
+			md.walk(joinKey(name, typeName), payload, val.FieldByIndex(field.Index))
+		}
+
+	case []interface{}:
+		for i, value := range d {
+			var elem reflect.Value
+			if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+				elem = reflect.Zero(val.Type().Elem())
+				if i < val.Len() {
+					elem = val.Index(i)
+				}
+			}
+
+			md.walkUnion(name+"["+strconv.Itoa(i)+"]", value, elem)
+		}
+	}
+}
+
+// walkUntagged records the type selected by the untagged union val, or by
+// each of its elements, and the keys of its payload
+func (md *Metadata) walkUntagged(name string, data interface{}, val reflect.Value) {
+	if dataSlice, ok := data.([]interface{}); ok && val.Kind() == reflect.Slice {
+		for i, value := range dataSlice {
+			if i < val.Len() {
+				md.walkUntagged(name+"["+strconv.Itoa(i)+"]", value, val.Index(i))
+			}
+		}
+		return
+	}
+
+	if val.Kind() != reflect.Struct {
+		return
+	}
+
+	if typeName, payload, ok := untaggedType(val); ok {
+		md.DynamicTypes[name] = typeName
+		md.walk(name, data, payload)
+	}
+}
+
+// recordDynamic records the type selected by a dynamic field, or by each
+// element of a dynamic slice
+func (md *Metadata) recordDynamic(name string, data interface{}, parent map[string]interface{}, tag fieldTag) {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if dynType, ok, _ := dynamicSelector(name, reflect.ValueOf(d), reflect.ValueOf(parent), tag); ok {
+			md.DynamicTypes[name] = dynType
+		}
+	case []interface{}:
+		for i, value := range d {
+			md.recordDynamic(name+"["+strconv.Itoa(i)+"]", value, parent, tag)
+		}
+	}
+}
+
+// joinKey returns the key path of key inside name
+func joinKey(name, key string) string {
+	if name == "" {
+		return key
+	}
+	return name + "." + key
+}
@@ -0,0 +1,65 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type MetaServer struct {
+	Host    string `mirror:"host"`
+	Port    int    `mirror:"port,optional"`
+	Timeout int    `mirror:"timeout,optional"`
+}
+
+type MetaConfig struct {
+	Name    string       `mirror:"name"`
+	Server  MetaServer   `mirror:"server"`
+	Extra   DynTyp       `mirror:"extra,dynamic=type"`
+	Plugins []DynTyp     `mirror:"plugins,dynamic=type"`
+	Backup  *MetaServer  `mirror:"backup,optional"`
+	Mirrors []MetaServer `mirror:"mirrors,optional"`
+}
+
+func TestMetadata(t *testing.T) {
+
+	yamlContent := []byte(`
+name: meta
+server:
+  host: localhost
+  unknown: 1
+extra:
+  type: int
+  value: 10
+plugins:
+  - type: int
+    value: 1
+`)
+
+	want := Metadata{
+		Keys: []string{
+			"extra", "extra.type", "extra.value", "name", "plugins",
+			"plugins[0].type", "plugins[0].value", "server", "server.host",
+		},
+		Unused:   []string{"server.unknown"},
+		Unset:    []string{"backup", "mirrors", "server.port"},
+		Defaults: []string{"server.timeout"},
+		DynamicTypes: map[string]string{
+			"extra":      "int",
+			"plugins[0]": "int",
+		},
+	}
+
+	var md Metadata
+	config := MetaConfig{Server: MetaServer{Timeout: 30}}
+	err := UnmarshalYaml(yamlContent, &config, WithMetadata(&md))
+
+	assert.EqualError(t, err, "decode map: 1 error(s) decoding:\n\n* detected unused keys: unknown")
+	assert.Equal(t, want, md)
+
+	md = Metadata{}
+	err = Decode(map[string]interface{}{"host": "localhost"}, &MetaServer{}, WithMetadata(&md))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host"}, md.Keys)
+	assert.Equal(t, []string{"port", "timeout"}, md.Unset)
+}
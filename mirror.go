@@ -1,13 +1,13 @@
 package mirror
 
 import (
-	"encoding/json"
 	"fmt"
-	"gopkg.in/yaml.v2"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type DynamicStruct interface {
@@ -15,34 +15,27 @@ type DynamicStruct interface {
 }
 
 // Unmarshal full yaml into the configuration structure
-func UnmarshalYaml(data []byte, config interface{}) error {
-
-	rawmap := make(map[string]interface{})
-
-	err := yaml.Unmarshal([]byte(data), rawmap)
-	if err != nil {
-		return fmt.Errorf("unmarshal yaml: %s", err)
-	}
-
-	err = decodeMapLevels(rawmap, config)
-	if err != nil {
-		return fmt.Errorf("decode map: %s", err)
-	}
-
-	return nil
+func UnmarshalYaml(data []byte, config interface{}, opts ...Option) error {
+	return unmarshal(yamlCodec{}, data, config, opts)
 }
 
-// Unmarshal full yaml into the configuration structure
-func UnmarshalJson(data []byte, config interface{}) error {
+// Unmarshal full json into the configuration structure
+func UnmarshalJson(data []byte, config interface{}, opts ...Option) error {
+	return unmarshal(jsonCodec{}, data, config, opts)
+}
 
-	rawmap := make(map[string]interface{})
+// Decode mirrors an already parsed tree, such as the map[string]interface{}
+// of a database document, a protobuf Struct or a kubernetes unstructured
+// object, into the configuration structure
+func Decode(input interface{}, config interface{}, opts ...Option) error {
 
-	err := json.Unmarshal([]byte(data), &rawmap)
+	rawmap, err := normalizeTree(input)
 	if err != nil {
-		return fmt.Errorf("unmarshal json: %s", err)
+		return fmt.Errorf("decode map: %s", err)
 	}
 
 	err = decodeMapLevels(rawmap, config)
+	newOptions(opts).recordMetadata(rawmap, config)
 	if err != nil {
 		return fmt.Errorf("decode map: %s", err)
 	}
@@ -52,11 +45,18 @@ func UnmarshalJson(data []byte, config interface{}) error {
 
 // decodeMapLevel decodes a single map level into the config structure
 func decodeMapLevels(input interface{}, output interface{}) error {
-	return decode("", input, reflect.ValueOf(output).Elem())
+	outVal := reflect.ValueOf(output)
+	if outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		return fmt.Errorf("output must be a non nil pointer, got '%T'", output)
+	}
+
+	return decode("", "", input, outVal.Elem())
 }
 
-// Decodes an unknown data type into a specific reflection value.
-func decode(name string, input interface{}, outVal reflect.Value) error {
+// Decodes an unknown data type into a specific reflection value. name is
+// the path of the value built from the struct field names, used by the
+// errors, and key the path of its map keys, used by the key suggestions.
+func decode(name, key string, input interface{}, outVal reflect.Value) error {
 	var inputVal reflect.Value
 	if input != nil {
 		inputVal = reflect.ValueOf(input)
@@ -68,8 +68,16 @@ func decode(name string, input interface{}, outVal reflect.Value) error {
 		}
 	}
 
+	if outVal.Type() == optionalType {
+		return decodeOptional(input, outVal)
+	}
+
 	if input == nil {
-		return fmt.Errorf("input is nil")
+		return decodeNull(name, outVal)
+	}
+
+	if outVal.Type() == timeType {
+		return decodeTime(name, input, outVal)
 	}
 
 	if !inputVal.IsValid() {
@@ -82,7 +90,7 @@ func decode(name string, input interface{}, outVal reflect.Value) error {
 	case reflect.Bool:
 		err = decodeBool(name, input, outVal)
 	case reflect.Interface:
-		err = decodeBasic(name, input, outVal)
+		err = decodeBasic(name, key, input, outVal)
 	case reflect.String:
 		err = decodeString(name, input, outVal)
 	case reflect.Int:
@@ -94,13 +102,13 @@ func decode(name string, input interface{}, outVal reflect.Value) error {
 	case reflect.Float64:
 		err = decodeFloat(name, input, outVal)
 	case reflect.Struct:
-		err = decodeStruct(name, input, outVal)
+		err = decodeStruct(name, key, input, outVal)
 	case reflect.Ptr:
-		_, err = decodePtr(name, input, outVal)
+		_, err = decodePtr(name, key, input, outVal)
 	case reflect.Slice:
-		err = decodeSlice(name, input, outVal)
+		err = decodeSlice(name, key, input, outVal)
 	case reflect.Array:
-		err = decodeArray(name, input, outVal)
+		err = decodeArray(name, key, input, outVal)
 	default:
 		// If we reached this point then we weren't able to decode it
 		return fmt.Errorf("%s: unsupported type: %s", name, outputKind)
@@ -113,6 +121,13 @@ func decodeBool(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataKind := getKind(dataVal)
 
+	// Yaml 1.2 parses yes, no, on and off as strings, keep accepting the
+	// plain yaml 1.1 boolean words for bool fields
+	if word, ok := data.(yamlBoolWord); ok {
+		val.SetBool(yamlBools[string(word)])
+		return nil
+	}
+
 	if dataKind != reflect.Bool {
 		return fmt.Errorf(
 			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
@@ -127,6 +142,22 @@ func decodeInt(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataKind := getKind(dataVal)
 
+	// Numbers parsed from json are float64, accept them when integral
+	if dataKind == reflect.Float64 && isIntegral(dataVal.Float()) {
+		val.SetInt(int64(dataVal.Float()))
+		return nil
+	}
+
+	// Durations are written as strings such as 5s or 1m30s
+	if dataKind == reflect.String && val.Type() == durationType {
+		d, err := time.ParseDuration(dataVal.String())
+		if err != nil {
+			return fmt.Errorf("'%s' invalid duration '%s': %s", name, dataVal.String(), err)
+		}
+		val.SetInt(int64(d))
+		return nil
+	}
+
 	if dataKind != reflect.Int {
 		return fmt.Errorf(
 			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
@@ -141,6 +172,12 @@ func decodeUint(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataKind := getKind(dataVal)
 
+	// Numbers parsed from json are float64, accept them when integral
+	if dataKind == reflect.Float64 && isIntegral(dataVal.Float()) && dataVal.Float() >= 0 {
+		val.SetUint(uint64(dataVal.Float()))
+		return nil
+	}
+
 	if dataKind != reflect.Uint {
 		return fmt.Errorf(
 			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
@@ -180,7 +217,43 @@ func decodeString(name string, data interface{}, val reflect.Value) error {
 	return nil
 }
 
-func decodePtr(name string, data interface{}, val reflect.Value) (bool, error) {
+// timeType is decoded from toml datetimes and from timestamp strings
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeFormats are the timestamp formats of yaml, RFC 3339 included
+var timeFormats = []string{
+	time.RFC3339Nano,
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+func decodeTime(name string, data interface{}, val reflect.Value) error {
+	dataVal := reflect.Indirect(reflect.ValueOf(data))
+
+	if dataVal.Type() == timeType {
+		val.Set(dataVal)
+		return nil
+	}
+
+	if getKind(dataVal) == reflect.String {
+		for _, format := range timeFormats {
+			if t, err := time.Parse(format, dataVal.String()); err == nil {
+				val.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+
+		return fmt.Errorf("'%s' invalid timestamp '%s'", name, dataVal.String())
+	}
+
+	return fmt.Errorf(
+		"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
+		name, val.Type(), dataVal.Type(), data)
+}
+
+func decodePtr(name, key string, data interface{}, val reflect.Value) (bool, error) {
 	// If the input data is nil, then we want to just set the output
 	// pointer to be nil as well.
 	isNil := data == nil
@@ -214,13 +287,13 @@ func decodePtr(name string, data interface{}, val reflect.Value) (bool, error) {
 			realVal = reflect.New(valElemType)
 		}
 
-		if err := decode(name, data, reflect.Indirect(realVal)); err != nil {
+		if err := decode(name, key, data, reflect.Indirect(realVal)); err != nil {
 			return false, err
 		}
 
 		val.Set(realVal)
 	} else {
-		if err := decode(name, data, reflect.Indirect(val)); err != nil {
+		if err := decode(name, key, data, reflect.Indirect(val)); err != nil {
 			return false, err
 		}
 	}
@@ -229,7 +302,7 @@ func decodePtr(name string, data interface{}, val reflect.Value) (bool, error) {
 
 // This decodes a basic type (bool, int, string, etc.) and sets the
 // value to "data" of that type.
-func decodeBasic(name string, data interface{}, val reflect.Value) error {
+func decodeBasic(name, key string, data interface{}, val reflect.Value) error {
 
 	if val.IsValid() && val.Elem().IsValid() {
 		elem := val.Elem()
@@ -253,7 +326,7 @@ func decodeBasic(name string, data interface{}, val reflect.Value) error {
 
 		// Decode. If we have an error then return. We also return right
 		// away if we're not a copy because that means we decoded directly.
-		if err := decode(name, data, elem); err != nil || !copied {
+		if err := decode(name, key, data, elem); err != nil || !copied {
 			return err
 		}
 
@@ -286,7 +359,7 @@ func decodeBasic(name string, data interface{}, val reflect.Value) error {
 	return nil
 }
 
-func decodeSlice(name string, data interface{}, val reflect.Value) error {
+func decodeSlice(name, key string, data interface{}, val reflect.Value) error {
 
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataValKind := dataVal.Kind()
@@ -322,7 +395,8 @@ func decodeSlice(name string, data interface{}, val reflect.Value) error {
 		currentField := valSlice.Index(i)
 
 		fieldName := name + "[" + strconv.Itoa(i) + "]"
-		if err := decode(fieldName, currentData, currentField); err != nil {
+		fieldKey := key + "[" + strconv.Itoa(i) + "]"
+		if err := decode(fieldName, fieldKey, currentData, currentField); err != nil {
 			errors = appendErrors(errors, err)
 		}
 	}
@@ -338,7 +412,7 @@ func decodeSlice(name string, data interface{}, val reflect.Value) error {
 	return nil
 }
 
-func decodeArray(name string, data interface{}, val reflect.Value) error {
+func decodeArray(name, key string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataValKind := dataVal.Kind()
 	valType := val.Type()
@@ -372,7 +446,8 @@ func decodeArray(name string, data interface{}, val reflect.Value) error {
 		currentField := valArray.Index(i)
 
 		fieldName := name + "[" + strconv.Itoa(i) + "]"
-		if err := decode(fieldName, currentData, currentField); err != nil {
+		fieldKey := key + "[" + strconv.Itoa(i) + "]"
+		if err := decode(fieldName, fieldKey, currentData, currentField); err != nil {
 			errors = appendErrors(errors, err)
 		}
 	}
@@ -388,7 +463,7 @@ func decodeArray(name string, data interface{}, val reflect.Value) error {
 	return nil
 }
 
-func decodeStruct(name string, data interface{}, val reflect.Value) error {
+func decodeStruct(name, key string, data interface{}, val reflect.Value) error {
 
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 
@@ -407,10 +482,10 @@ func decodeStruct(name string, data interface{}, val reflect.Value) error {
 			name, dataVal.Type(), data)
 	}
 
-	return decodeStructFromMap(name, dataVal, val)
+	return decodeStructFromMap(name, key, dataVal, val)
 }
 
-func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
+func decodeStructFromMap(name, key string, dataVal, val reflect.Value) error {
 
 	dataValType := dataVal.Type()
 	if kind := dataValType.Key().Kind(); kind != reflect.String && kind != reflect.Interface {
@@ -428,6 +503,7 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 	}
 
 	errors := make([]string, 0)
+	missing := make([]string, 0)
 
 	type field struct {
 		field reflect.StructField
@@ -451,28 +527,45 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 		fieldName := field.Name
 
 		// look for tags
-		tags := field.Tag.Get("mirror")
-		tagSlice := strings.Split(tags, ",")
-		tagValue := tagSlice[0]
-
-		tagDynamic := ""
-		if len(tagSlice) > 1 {
-			tagDynamic = tagSlice[1]
+		tag, err := parseTag(field)
+		if err != nil {
+			return fmt.Errorf("'%s' %s", name, err)
 		}
+		tagValue := tag.Name
 
 		if tagValue == "" {
 			errors = append(errors, "missing `mirror` tag for struct field: "+fieldName)
 		}
 
-		// cast to type if tagDynamic is present
-		if tagDynamic != "" {
+		// externally tagged unions are rewritten in internally tagged form
+		var rewritten interface{}
+		if tag.Union == unionExternal && dataVal.MapIndex(reflect.ValueOf(tagValue)).IsValid() &&
+			!isNullValue(dataVal.MapIndex(reflect.ValueOf(tagValue))) {
 
-			if !strings.HasPrefix(tagDynamic, "dynamic=") {
-				return fmt.Errorf("'%s' invalid dynamic selector tag", name)
+			rewritten, err = decodeExternalUnion(joinKey(name, fieldName), dataVal.MapIndex(reflect.ValueOf(tagValue)), fieldValue, tag)
+			if err != nil {
+				errors = appendErrors(errors, err)
+				delete(dataValKeysUnused, tagValue)
+				continue
 			}
+		}
 
-			selectValue := strings.Split(tagDynamic, "=")[1]
-			rawMapSelectKey := reflect.ValueOf(selectValue)
+		// untagged unions are decoded by trying every registered type
+		if tag.Union == unionUntagged && dataVal.MapIndex(reflect.ValueOf(tagValue)).IsValid() &&
+			!isNullValue(dataVal.MapIndex(reflect.ValueOf(tagValue))) {
+
+			delete(dataValKeysUnused, tagValue)
+			if err := decodeUntaggedUnion(joinKey(name, fieldName), joinKey(key, tagValue), dataVal.MapIndex(reflect.ValueOf(tagValue)).Interface(), fieldValue); err != nil {
+				errors = appendErrors(errors, err)
+			}
+			continue
+		}
+
+		// cast to type if the dynamic selector is present
+		if tag.Dynamic != "" && tag.Union == "" && dataVal.MapIndex(reflect.ValueOf(tagValue)).IsValid() &&
+			!isNullValue(dataVal.MapIndex(reflect.ValueOf(tagValue))) {
+
+			selectValue := tag.Dynamic
 			rawMapKey := reflect.ValueOf(tagValue)
 			rawMapVal := dataVal.MapIndex(rawMapKey)
 
@@ -490,37 +583,65 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 
 				// Cast dynamic type for each element of slice
 				for i := 0; i < rawMapVal.Elem().Len(); i++ {
-					rawMapSelectVal := rawMapVal.Elem().Index(i).Elem().MapIndex(rawMapSelectKey)
+					dynName := fmt.Sprintf("%s[%d]", joinKey(name, fieldName), i)
+
+					dynType, ok, err := dynamicSelector(dynName, rawMapVal.Elem().Index(i), dataVal, tag)
+					if err != nil {
+						errors = append(errors, err.Error())
+						continue
+					}
 
-					if !rawMapSelectVal.IsValid() {
+					if !ok {
 						errors = append(errors, "map value not found in slice element for dynamic selector: "+selectValue)
 						continue
 					}
 
-					valSlice.Index(i).Addr().Interface().(DynamicStruct).SetDynamicType(rawMapSelectVal.Interface().(string))
+					if err := checkDynamicType(dynName, valElemType, dynType); err != nil {
+						errors = append(errors, err.Error())
+						continue
+					}
+
+					valSlice.Index(i).Addr().Interface().(DynamicStruct).SetDynamicType(dynType)
 				}
 
 				// Finally, set the value to the slice we built up
 				fieldValue.Set(valSlice)
 
 			} else {
-				rawMapSelectVal := rawMapVal.Elem().MapIndex(rawMapSelectKey)
+				dynName := joinKey(name, fieldName)
 
-				if !rawMapSelectVal.IsValid() {
+				dynType, ok, err := dynamicSelector(dynName, rawMapVal, dataVal, tag)
+				if err != nil {
+					errors = append(errors, err.Error())
+					continue
+				}
+
+				if !ok {
 					errors = append(errors, "map value not found for dynamic selector: "+selectValue)
 					continue
 				}
 
-				fieldValue.Addr().Interface().(DynamicStruct).SetDynamicType(rawMapSelectVal.Interface().(string))
+				if err := checkDynamicType(dynName, fieldValue.Type(), dynType); err != nil {
+					errors = append(errors, err.Error())
+					continue
+				}
+
+				fieldValue.Addr().Interface().(DynamicStruct).SetDynamicType(dynType)
 			}
 
+			// The selector field of elements using the default type
+			// decodes the default selector
+			rewritten = defaultSelector(rawMapVal.Interface(), fieldValue.Type(), tag)
 		}
 
 		rawMapKey := reflect.ValueOf(tagValue)
 		rawMapVal := dataVal.MapIndex(rawMapKey)
 
 		if !rawMapVal.IsValid() {
-			errors = append(errors, "map value not found for key: "+tagValue)
+			if !tag.Optional {
+				errors = append(errors, "map value not found for key: "+tagValue)
+				missing = append(missing, tagValue)
+			}
 			continue
 		}
 
@@ -545,7 +666,19 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 			fieldName = name + "." + fieldName
 		}
 
-		if err := decode(fieldName, rawMapVal.Interface(), fieldValue); err != nil {
+		// A null optional field is left to its zero value, even when not
+		// nullable
+		if tag.Optional && isNullValue(rawMapVal) && fieldValue.Type() != optionalType {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			continue
+		}
+
+		fieldData := rawMapVal.Interface()
+		if rewritten != nil {
+			fieldData = rewritten
+		}
+
+		if err := decode(fieldName, joinKey(key, tagValue), fieldData, fieldValue); err != nil {
 			errors = appendErrors(errors, err)
 		}
 	}
@@ -556,6 +689,24 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 		dataValKeysUnusedString = append(dataValKeysUnusedString, key)
 	}
 	sort.Strings(dataValKeysUnusedString)
+
+	// Pair the unused and missing keys looking like typos of each other
+	suggestions := suggestKeys(missing, dataValKeysUnusedString)
+	if len(suggestions) > 0 {
+		errors = removeMissingErrors(errors, suggestions)
+
+		unused := []string{}
+		for _, unusedKey := range dataValKeysUnusedString {
+			suggestion, ok := suggestions[unusedKey]
+			if !ok {
+				unused = append(unused, unusedKey)
+				continue
+			}
+			errors = append(errors, fmt.Sprintf("%s: unknown key, did you mean '%s'?", joinKey(key, unusedKey), suggestion))
+		}
+		dataValKeysUnusedString = unused
+	}
+
 	if len(dataValKeysUnusedString) > 0 {
 		errors = append(errors, "detected unused keys: "+strings.Join(dataValKeysUnusedString, " "))
 	}
@@ -567,6 +718,11 @@ func decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 	return nil
 }
 
+// isNullValue reports if the map value val holds a null
+func isNullValue(val reflect.Value) bool {
+	return val.Kind() == reflect.Interface && val.IsNil()
+}
+
 func getKind(val reflect.Value) reflect.Kind {
 	kind := val.Kind()
 
@@ -581,3 +737,9 @@ func getKind(val reflect.Value) reflect.Kind {
 		return kind
 	}
 }
+
+// isIntegral reports if the float f holds an integer value in the int64
+// range
+func isIntegral(f float64) bool {
+	return f == math.Trunc(f) && math.Abs(f) < 1<<63
+}
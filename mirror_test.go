@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestDecodeBool(t *testing.T) {
@@ -25,6 +26,10 @@ func TestDecodeBool(t *testing.T) {
 		{"bool 1", true, true, false},
 		{"bool 2", false, false, false},
 		{"bool 3", 1, false, true},
+		{"bool yes", yamlBoolWord("yes"), true, false},
+		{"bool off", yamlBoolWord("Off"), false, false},
+		{"bool string yes", "yes", false, true},
+		{"bool string", "maybe", false, true},
 	}
 	for _, tt := range tests_ok {
 		tt := tt
@@ -58,6 +63,8 @@ func TestDecodeInt(t *testing.T) {
 		{"int 2", 2147483649, 2147483649, false},
 		{"int 3", -2147483649, -2147483649, false},
 		{"int 4", int64(1), 1, false},
+		{"int 5", float64(-3), -3, false},
+		{"int 6", 1.5, 0, true},
 	}
 	for _, tt := range tests_ok {
 		tt := tt
@@ -79,6 +86,38 @@ func TestDecodeInt(t *testing.T) {
 	}
 }
 
+func TestDecodeDuration(t *testing.T) {
+	t.Parallel()
+
+	tests_ok := []struct {
+		name string
+		data interface{}
+		want time.Duration
+		err  bool
+	}{
+		{"duration int", 1000, 1000, false},
+		{"duration string", "1m30s", 90 * time.Second, false},
+		{"duration invalid", "soon", 0, true},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var value time.Duration
+			val := reflect.ValueOf(&value).Elem()
+			err := decodeInt(tt.name, tt.data, val)
+
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+
+				assert.Equal(t, tt.want, value)
+			}
+		})
+	}
+}
+
 func TestDecodeUint(t *testing.T) {
 	t.Parallel()
 
@@ -91,6 +130,8 @@ func TestDecodeUint(t *testing.T) {
 		{"uint 1", uint(1), 1, false},
 		{"uint 2", uint(2147483649), 2147483649, false},
 		{"uint 3", int(2147483649), 2147483649, true},
+		{"uint 4", float64(3), 3, false},
+		{"uint 5", float64(-3), 0, true},
 	}
 	for _, tt := range tests_ok {
 		tt := tt
@@ -208,7 +249,7 @@ func TestDecodePtr(t *testing.T) {
 			ptr := &value
 
 			valptr := reflect.ValueOf(ptr)
-			ret, err := decodePtr(tt.name, tt.data, valptr)
+			ret, err := decodePtr(tt.name, "", tt.data, valptr)
 
 			if tt.err {
 				assert.Error(t, err)
@@ -244,7 +285,7 @@ func TestDecodeSlice(t *testing.T) {
 			var slc []int
 
 			val := reflect.ValueOf(&slc).Elem()
-			err := decodeSlice(tt.name, tt.data, val)
+			err := decodeSlice(tt.name, "", tt.data, val)
 
 			if tt.err {
 				assert.Error(t, err)
@@ -278,7 +319,7 @@ func TestDecodeArray(t *testing.T) {
 			var slc [2]int
 
 			val := reflect.ValueOf(&slc).Elem()
-			err := decodeArray(tt.name, tt.data, val)
+			err := decodeArray(tt.name, "", tt.data, val)
 
 			if tt.err {
 				assert.Error(t, err)
@@ -323,7 +364,7 @@ func TestDecodeStructFromMapSimple(t *testing.T) {
 
 	var result Person
 	val := reflect.ValueOf(&result).Elem()
-	err := decodeStructFromMap("struct", reflect.Indirect(reflect.ValueOf(input)), val)
+	err := decodeStructFromMap("struct", "", reflect.Indirect(reflect.ValueOf(input)), val)
 
 	assert.NoError(t, err)
 	assert.Equal(t, want, val.Interface())
@@ -356,15 +397,14 @@ func TestDecodeStructFromMapErrors(t *testing.T) {
 		Errors: []string{
 			"missing `mirror` tag for struct field: Name",
 			"map value not found for key: ",
-			"map value not found for key: twit",
-			"map value not found for key: med",
-			"detected unused keys: medium twitter",
+			"extra.medium: unknown key, did you mean 'med'?",
+			"extra.twitter: unknown key, did you mean 'twit'?",
 			"detected unused keys: emails name"},
 	}
 
 	var result Person
 	val := reflect.ValueOf(&result).Elem()
-	err := decodeStructFromMap("struct", reflect.Indirect(reflect.ValueOf(input)), val)
+	err := decodeStructFromMap("struct", "", reflect.Indirect(reflect.ValueOf(input)), val)
 
 	t.Log(err)
 
@@ -414,7 +454,7 @@ func TestDecodeStructFromMapDynamic(t *testing.T) {
 
 	var result Person
 	val := reflect.ValueOf(&result).Elem()
-	err := decodeStructFromMap("struct", reflect.Indirect(reflect.ValueOf(input)), val)
+	err := decodeStructFromMap("struct", "", reflect.Indirect(reflect.ValueOf(input)), val)
 
 	assert.NoError(t, err)
 	assert.Equal(t, want, val.Interface())
@@ -447,8 +487,306 @@ func TestDecodeStructFromMapDynamicErr(t *testing.T) {
 
 	var result Person
 	val := reflect.ValueOf(&result).Elem()
-	err := decodeStructFromMap("struct", reflect.Indirect(reflect.ValueOf(input)), val)
+	err := decodeStructFromMap("struct", "", reflect.Indirect(reflect.ValueOf(input)), val)
 
 	assert.Error(t, err)
 	assert.Equal(t, wanterr, err)
 }
+
+func TestDecodeStructFromMapOptional(t *testing.T) {
+
+	type Person struct {
+		Name string `mirror:"name"`
+		Age  int    `mirror:"age,optional"`
+	}
+
+	input := map[interface{}]interface{}{
+		"name": "lumontec",
+	}
+
+	var want = Person{
+		Name: "lumontec",
+		Age:  91,
+	}
+
+	result := Person{Age: 91}
+	val := reflect.ValueOf(&result).Elem()
+	err := decodeStructFromMap("struct", "", reflect.Indirect(reflect.ValueOf(input)), val)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, val.Interface())
+}
+
+func TestDecode(t *testing.T) {
+
+	type ExtraTyp struct {
+		Twitter string `mirror:"twitter"`
+	}
+
+	type Person struct {
+		Name   string   `mirror:"name"`
+		Age    int      `mirror:"age"`
+		Emails []string `mirror:"emails"`
+		Extra  ExtraTyp `mirror:"extra"`
+	}
+
+	input := map[string]interface{}{
+		"name":   "lumontec",
+		"age":    float64(91),
+		"emails": []interface{}{"one", "two"},
+		"extra": map[interface{}]interface{}{
+			"twitter": "lumontec",
+		},
+	}
+
+	var want = Person{
+		Name:   "lumontec",
+		Age:    91,
+		Emails: []string{"one", "two"},
+		Extra: ExtraTyp{
+			Twitter: "lumontec",
+		},
+	}
+
+	var result Person
+	err := Decode(input, &result)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, result)
+
+	err = Decode(map[string]interface{}{"name": "lumontec", "unknown": 1}, &result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "detected unused keys: unknown")
+
+	err = Decode(input, result)
+	assert.EqualError(t, err, "decode map: output must be a non nil pointer, got 'mirror.Person'")
+}
+
+func TestUnmarshalYamlScalars(t *testing.T) {
+
+	type Locale struct {
+		Country string `mirror:"country"`
+		Enabled bool   `mirror:"enabled"`
+		Debug   bool   `mirror:"debug"`
+		Since   string `mirror:"since"`
+	}
+
+	yamlContent := []byte(`
+country: no
+enabled: yes
+debug: off
+since: 2001-12-14
+`)
+
+	var want = Locale{
+		Country: "no",
+		Enabled: true,
+		Debug:   false,
+		Since:   "2001-12-14",
+	}
+
+	var result Locale
+	err := UnmarshalYaml(yamlContent, &result)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, result)
+
+	err = UnmarshalYaml([]byte("country: no\nenabled: \"yes\"\ndebug: off\nsince: x\n"), &result)
+	assert.EqualError(t, err, "decode map: 1 error(s) decoding:\n\n"+
+		"* 'Enabled' expected type 'bool', got unconvertible type 'string', value: 'yes'")
+
+	err = UnmarshalJson([]byte(`{"country": "no", "enabled": "yes", "debug": false, "since": "x"}`), &result)
+	assert.EqualError(t, err, "decode map: 1 error(s) decoding:\n\n"+
+		"* 'Enabled' expected type 'bool', got unconvertible type 'string', value: 'yes'")
+
+	tree, err := yamlCodec{}.Decode([]byte("labels:\n  on: yes\n  nested:\n    key: value\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"labels": map[string]interface{}{
+			"on":     "yes",
+			"nested": map[string]interface{}{"key": "value"},
+		},
+	}, tree)
+}
+
+func TestUnmarshalTime(t *testing.T) {
+
+	type Event struct {
+		At   time.Time `mirror:"at"`
+		Day  time.Time `mirror:"day"`
+		Text string    `mirror:"text"`
+	}
+
+	want := Event{
+		At:   time.Date(2020, time.January, 1, 10, 30, 0, 0, time.UTC),
+		Day:  time.Date(2001, time.December, 14, 0, 0, 0, 0, time.UTC),
+		Text: "2001-12-14",
+	}
+
+	var yamlEvent Event
+	err := UnmarshalYaml([]byte("at: 2020-01-01T10:30:00Z\nday: 2001-12-14\ntext: 2001-12-14\n"), &yamlEvent)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, yamlEvent)
+
+	var jsonEvent Event
+	err = UnmarshalJson([]byte(`{"at": "2020-01-01T10:30:00Z", "day": "2001-12-14", "text": "2001-12-14"}`), &jsonEvent)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, jsonEvent)
+
+	var setEvent Event
+	err = Load(&setEvent, SetSource("at=2020-01-01T10:30:00Z", "day=2001-12-14", "text=2001-12-14"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, setEvent)
+
+	err = UnmarshalJson([]byte(`{"at": "tomorrow", "day": 1, "text": ""}`), &jsonEvent)
+
+	assert.EqualError(t, err, "decode map: 2 error(s) decoding:\n\n"+
+		"* 'At' invalid timestamp 'tomorrow'\n"+
+		"* 'Day' expected type 'time.Time', got unconvertible type 'float64', value: '1'")
+}
+
+type SelTyp struct {
+	Version Optional    `mirror:"version"`
+	Value   interface{} `mirror:"value"`
+}
+
+func (e *SelTyp) SetDynamicType(Type string) {
+	switch Type {
+	case "myint", "2":
+		e.Value = int(0)
+	case "true":
+		e.Value = ""
+	}
+}
+
+func TestDecodeStructFromMapDynamicDefault(t *testing.T) {
+
+	type Config struct {
+		Elements []SelTyp `mirror:"elements,dynamic=version,dyndefault=myint"`
+	}
+
+	yamlContent := []byte(`
+elements:
+  - value: 1
+  - version: 2
+    value: 2
+  - version: true
+    value: three
+`)
+
+	var want = Config{
+		Elements: []SelTyp{
+			{Value: 1},
+			{Version: Optional{KeySet, 2}, Value: 2},
+			{Version: Optional{KeySet, true}, Value: "three"},
+		},
+	}
+
+	var config Config
+	err := UnmarshalYaml(yamlContent, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	config = Config{}
+	err = UnmarshalJson([]byte(`{"elements": [{"version": 2, "value": 2}]}`), &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, config.Elements[0].Value)
+
+	config = Config{}
+	err = UnmarshalYaml([]byte("elements:\n  - version: 1.5\n    value: 1\n"), &config)
+
+	assert.EqualError(t, err, "decode map: 1 error(s) decoding:\n\n"+
+		"* 'Elements[0]' dynamic selector 'version' must be a string, an integer or a boolean, got '1.5'")
+
+	// A required selector field decodes the default selector
+	type Required struct {
+		Element  DynTyp   `mirror:"e,dynamic=type,dyndefault=int"`
+		Elements []DynTyp `mirror:"es,dynamic=type,dyndefault=int"`
+	}
+
+	var required Required
+	err = UnmarshalYaml([]byte("e: {value: 3}\nes: [{value: 4}, {type: int, value: 5}]\n"), &required)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Required{
+		Element:  DynTyp{Type: "int", Value: 3},
+		Elements: []DynTyp{{Type: "int", Value: 4}, {Type: "int", Value: 5}},
+	}, required)
+}
+
+type SpecTyp struct {
+	Spec interface{} `mirror:"spec"`
+}
+
+func (e *SpecTyp) SetDynamicType(Type string) {
+	switch Type {
+	case "Deployment":
+		e.Spec = &DeploymentSpec{}
+	}
+}
+
+type DeploymentSpec struct {
+	Replicas int `mirror:"replicas"`
+}
+
+type MetaTyp struct {
+	Metadata struct {
+		Type string `mirror:"type"`
+	} `mirror:"metadata"`
+	Value interface{} `mirror:"value"`
+}
+
+func (e *MetaTyp) SetDynamicType(Type string) {
+	switch Type {
+	case "int":
+		e.Value = int(0)
+	}
+}
+
+func TestDecodeStructFromMapDynamicPath(t *testing.T) {
+
+	type Object struct {
+		Kind     string    `mirror:"kind"`
+		Body     SpecTyp   `mirror:"body,dynamic=../kind"`
+		Elements []MetaTyp `mirror:"elements,dynamic=metadata.type"`
+	}
+
+	yamlContent := []byte(`
+kind: Deployment
+body:
+  spec:
+    replicas: 3
+elements:
+  - metadata:
+      type: int
+    value: 1
+`)
+
+	var config Object
+	err := UnmarshalYaml(yamlContent, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployment", config.Kind)
+	assert.Equal(t, &DeploymentSpec{Replicas: 3}, config.Body.Spec)
+	assert.Equal(t, "int", config.Elements[0].Metadata.Type)
+	assert.Equal(t, 1, config.Elements[0].Value)
+
+	config = Object{}
+	err = UnmarshalYaml([]byte("kind: Deployment\nbody:\n  spec: {}\nelements:\n  - value: 1\n"), &config)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "map value not found in slice element for dynamic selector: metadata.type")
+
+	type Invalid struct {
+		Body SpecTyp `mirror:"body,dynamic=../../kind"`
+	}
+
+	err = UnmarshalYaml([]byte("body: {}\n"), &Invalid{})
+	assert.EqualError(t, err, "decode map: '' invalid dynamic selector '../../kind' for struct field: Body")
+}
@@ -0,0 +1,81 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KeyState tells apart a missing key, a key set to null and a key set to a
+// value
+type KeyState int
+
+const (
+	KeyAbsent KeyState = iota
+	KeyNull
+	KeySet
+)
+
+func (s KeyState) String() string {
+	switch s {
+	case KeyAbsent:
+		return "absent"
+	case KeyNull:
+		return "null"
+	case KeySet:
+		return "set"
+	default:
+		return fmt.Sprintf("KeyState(%d)", int(s))
+	}
+}
+
+// Optional is a field type recording whether its key was absent, null or
+// set, keeping the raw value for a later Decode. Optional fields are never
+// reported as missing.
+//
+//	type Config struct {
+//		Timeout mirror.Optional `mirror:"timeout"`
+//	}
+type Optional struct {
+	State KeyState
+	Raw   interface{}
+}
+
+var optionalType = reflect.TypeOf(Optional{})
+
+// Decode mirrors the raw value into out, which must be a non nil pointer.
+// Null and absent values leave out untouched.
+func (o Optional) Decode(out interface{}) error {
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		return fmt.Errorf("output must be a non nil pointer, got '%T'", out)
+	}
+
+	if o.State != KeySet {
+		return nil
+	}
+
+	return decode("", "", o.Raw, outVal.Elem())
+}
+
+// decodeOptional records the state and the raw value of a present key
+func decodeOptional(data interface{}, val reflect.Value) error {
+	optional := Optional{State: KeySet, Raw: plainTree(data)}
+	if data == nil {
+		optional.State = KeyNull
+	}
+
+	val.Set(reflect.ValueOf(optional))
+	return nil
+}
+
+// decodeNull decodes a null value: pointers, slices, maps and interfaces
+// are set to nil, other types are not nullable
+func decodeNull(name string, val reflect.Value) error {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	default:
+		return fmt.Errorf("'%s' null value for non nullable type '%s'", name, val.Type())
+	}
+}
@@ -0,0 +1,95 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type NullServer struct {
+	Host string `mirror:"host"`
+}
+
+type NullConfig struct {
+	Name    *string       `mirror:"name"`
+	Labels  []string      `mirror:"labels"`
+	Extra   interface{}   `mirror:"extra"`
+	Server  *NullServer   `mirror:"server"`
+	Port    int           `mirror:"port,optional"`
+	Servers []*NullServer `mirror:"servers"`
+}
+
+func TestDecodeNull(t *testing.T) {
+
+	name := "preset"
+	config := NullConfig{
+		Name:   &name,
+		Labels: []string{"preset"},
+		Extra:  "preset",
+		Server: &NullServer{Host: "preset"},
+		Port:   80,
+	}
+
+	yamlContent := []byte(`
+name: ~
+labels: null
+extra:
+server:
+port: ~
+servers: [{host: a}, ~]
+`)
+
+	var want = NullConfig{
+		Servers: []*NullServer{{Host: "a"}, nil},
+	}
+
+	err := UnmarshalYaml(yamlContent, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	type Required struct {
+		Port   int        `mirror:"port"`
+		Server NullServer `mirror:"server"`
+		Ports  []int      `mirror:"ports"`
+	}
+
+	err = UnmarshalJson([]byte(`{"port": null, "server": null, "ports": [1, null]}`), &Required{})
+
+	assert.EqualError(t, err, "decode map: 3 error(s) decoding:\n\n"+
+		"* 'Port' null value for non nullable type 'int'\n"+
+		"* 'Ports[1]' null value for non nullable type 'int'\n"+
+		"* 'Server' null value for non nullable type 'mirror.NullServer'")
+}
+
+func TestOptional(t *testing.T) {
+
+	type Config struct {
+		Timeout Optional `mirror:"timeout"`
+		Retries Optional `mirror:"retries"`
+		Server  Optional `mirror:"server"`
+	}
+
+	var config Config
+	err := UnmarshalYaml([]byte("timeout: ~\nserver:\n  host: localhost\n"), &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Optional{State: KeyNull}, config.Timeout)
+	assert.Equal(t, Optional{State: KeyAbsent}, config.Retries)
+	assert.Equal(t, KeySet, config.Server.State)
+
+	var server NullServer
+	assert.NoError(t, config.Server.Decode(&server))
+	assert.Equal(t, NullServer{Host: "localhost"}, server)
+
+	timeout := 30
+	assert.NoError(t, config.Timeout.Decode(&timeout))
+	assert.Equal(t, 30, timeout)
+
+	assert.Error(t, config.Server.Decode(server))
+
+	// Raw values hold plain strings
+	err = UnmarshalYaml([]byte("timeout: yes\n"), &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Optional{State: KeySet, Raw: "yes"}, config.Timeout)
+}
@@ -0,0 +1,74 @@
+package mirror
+
+import (
+	"io/fs"
+	"log"
+)
+
+// Option configures the decoding of a document
+type Option func(*options)
+
+type options struct {
+	fsys         fs.FS
+	dir          string
+	includeDepth int
+	nodeLimit    int
+	metadata     *Metadata
+	onWarning    func(warning string)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		includeDepth: defaultIncludeDepth,
+		nodeLimit:    defaultNodeLimit,
+		onWarning: func(warning string) {
+			log.Printf("mirror: warning: %s", warning)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithIncludes enables the `!include file.yaml` yaml tag and the
+// {"$ref": "file.json#/section"} json object, included files are read from
+// fsys relative to dir, then relative to the including file.
+func WithIncludes(fsys fs.FS, dir string) Option {
+	return func(o *options) {
+		o.fsys = fsys
+		o.dir = dir
+	}
+}
+
+// WithIncludeDepth sets the maximum nesting of included files
+func WithIncludeDepth(depth int) Option {
+	return func(o *options) {
+		o.includeDepth = depth
+	}
+}
+
+// WithNodeLimit sets the maximum number of nodes of a yaml document once
+// its aliases are expanded, protecting against alias bombs
+func WithNodeLimit(limit int) Option {
+	return func(o *options) {
+		o.nodeLimit = limit
+	}
+}
+
+// WithWarningHandler sets the function called with the warnings of the
+// decoding, such as deprecated versions, which are logged by default
+func WithWarningHandler(handler func(warning string)) Option {
+	return func(o *options) {
+		o.onWarning = handler
+	}
+}
+
+// warn reports a warning to the handler of the options
+func (o *options) warn(warning string) {
+	if o.onWarning != nil {
+		o.onWarning(warning)
+	}
+}
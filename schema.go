@@ -0,0 +1,390 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// jsonSchemaDraft is the dialect of the generated schemas
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema generates a Draft 2020-12 JSON Schema from the mirror tags of
+// the structure v, describing the documents accepted by UnmarshalYaml and
+// UnmarshalJson. Dynamic fields produce a oneOf with a branch for every
+// type registered through RegisterDynamicType.
+func JSONSchema(v interface{}) ([]byte, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil, fmt.Errorf("schema: input is nil")
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	schema, err := typeSchema("", typ)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %s", err)
+	}
+	schema["$schema"] = jsonSchemaDraft
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema returns the schema matching the values decode accepts for typ
+func typeSchema(name string, typ reflect.Type) (map[string]interface{}, error) {
+	// Durations are nanoseconds or strings such as 5s
+	if typ == durationType {
+		return map[string]interface{}{"type": []string{"integer", "string"}}, nil
+	}
+
+	if typ == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch getTypeKind(typ) {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Struct:
+		return structSchema(name, typ)
+	case reflect.Ptr:
+		schema, err := typeSchema(name, typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullableSchema(schema), nil
+	case reflect.Slice:
+		items, err := typeSchema(name+"[]", typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": items}, nil
+	case reflect.Array:
+		items, err := typeSchema(name+"[]", typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items, "maxItems": typ.Len()}, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported type: %s", name, typ.Kind())
+	}
+}
+
+// structSchema returns an object schema listing every tagged field, unknown
+// keys are rejected as decodeStructFromMap does.
+func structSchema(name string, typ reflect.Type) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag, err := parseTag(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag.Name == "" {
+			return nil, fmt.Errorf("missing `mirror` tag for struct field: %s", field.Name)
+		}
+
+		fieldName := field.Name
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+
+		var fieldSchema map[string]interface{}
+		if tag.Union == unionExternal {
+			fieldSchema, err = externalUnionSchema(fieldName, field.Type)
+		} else if tag.Union == unionUntagged {
+			fieldSchema, err = untaggedUnionSchema(fieldName, field.Type)
+		} else if tag.Dynamic != "" {
+			fieldSchema, err = dynamicFieldSchema(fieldName, field.Type, tag)
+		} else {
+			fieldSchema, err = typeSchema(fieldName, field.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Null resets optional fields and nullable kinds
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Interface:
+			fieldSchema = nullableSchema(fieldSchema)
+		default:
+			if tag.Optional {
+				fieldSchema = nullableSchema(fieldSchema)
+			}
+		}
+
+		properties[tag.Name] = fieldSchema
+		if !tag.Optional {
+			required = append(required, tag.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+// nullableSchema returns schema accepting null as well
+func nullableSchema(schema map[string]interface{}) map[string]interface{} {
+	if acceptsNull(schema) {
+		return schema
+	}
+
+	switch typ := schema["type"].(type) {
+	case string:
+		schema["type"] = []string{typ, "null"}
+		return schema
+	case []string:
+		schema["type"] = append(append([]string(nil), typ...), "null")
+		return schema
+	}
+
+	return map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+}
+
+// acceptsNull reports if schema, as built by typeSchema, accepts null
+func acceptsNull(schema map[string]interface{}) bool {
+	if len(schema) == 0 {
+		return true
+	}
+
+	switch typ := schema["type"].(type) {
+	case string:
+		return typ == "null"
+	case []string:
+		for _, t := range typ {
+			if t == "null" {
+				return true
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, branch := range anyOf {
+			if b, ok := branch.(map[string]interface{}); ok && acceptsNull(b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// elementsSchema unwraps the pointers of typ and, for slices and arrays,
+// returns an array schema with elem giving the schema of the items. The
+// schema is nil for other types.
+func elementsSchema(name string, typ reflect.Type, elem func(name string, typ reflect.Type) (map[string]interface{}, error)) (reflect.Type, map[string]interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+		return typ, nil, nil
+	}
+
+	items, err := elem(name+"[]", typ.Elem())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schema := map[string]interface{}{"type": "array", "items": items}
+	if typ.Kind() == reflect.Array {
+		schema["maxItems"] = typ.Len()
+	}
+	return typ, schema, nil
+}
+
+// dynamicFieldSchema returns the schema of a field tagged with a dynamic
+// selector, which can be either a DynamicStruct or a slice of them. The
+// selector of the default type may be omitted.
+func dynamicFieldSchema(name string, typ reflect.Type, tag fieldTag) (map[string]interface{}, error) {
+	typ, schema, err := elementsSchema(name, typ, func(name string, typ reflect.Type) (map[string]interface{}, error) {
+		return dynamicFieldSchema(name, typ, tag)
+	})
+	if err != nil || schema != nil {
+		return schema, err
+	}
+
+	registered := registeredDynamicTypes(typ)
+	if len(registered) == 0 {
+		return typeSchema(name, typ)
+	}
+
+	// Selectors read from the parent object cannot discriminate the
+	// branches of the field schema, any of them may match
+	fromParent, keys := selectorPath(tag.Dynamic)
+
+	branches := make([]interface{}, 0, len(registered))
+	for _, dt := range registered {
+		branch, err := structSchema(name, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		properties := branch["properties"].(map[string]interface{})
+		if !fromParent {
+			setSelectorSchema(branch, keys, selectorSchema(dt.name), dt.name == tag.DynDefault)
+		}
+
+		// The payload of the branch replaces every interface field
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Type.Kind() != reflect.Interface {
+				continue
+			}
+
+			payload, err := typeSchema(name+"."+field.Name, dt.typ)
+			if err != nil {
+				return nil, err
+			}
+
+			tag, _ := parseTag(field)
+			properties[tag.Name] = payload
+		}
+
+		branches = append(branches, branch)
+	}
+
+	if fromParent {
+		return map[string]interface{}{"anyOf": branches}, nil
+	}
+	return map[string]interface{}{"oneOf": branches}, nil
+}
+
+// externalUnionSchema returns the schema of an externally tagged union
+// field, which can be either a DynamicStruct or a slice of them: an object
+// with a single key naming a registered type.
+func externalUnionSchema(name string, typ reflect.Type) (map[string]interface{}, error) {
+	typ, schema, err := elementsSchema(name, typ, externalUnionSchema)
+	if err != nil || schema != nil {
+		return schema, err
+	}
+
+	registered := registeredDynamicTypes(typ)
+	if len(registered) == 0 {
+		return map[string]interface{}{"type": "object", "minProperties": 1, "maxProperties": 1}, nil
+	}
+
+	branches := make([]interface{}, 0, len(registered))
+	for _, dt := range registered {
+		payload, err := typeSchema(name+"."+dt.name, dt.typ)
+		if err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{dt.name: payload},
+			"required":             []string{dt.name},
+			"additionalProperties": false,
+		})
+	}
+
+	return map[string]interface{}{"oneOf": branches}, nil
+}
+
+// untaggedUnionSchema returns the schema of an untagged union field, which
+// can be either a DynamicStruct or a slice of them: any of the registered
+// types.
+func untaggedUnionSchema(name string, typ reflect.Type) (map[string]interface{}, error) {
+	typ, schema, err := elementsSchema(name, typ, untaggedUnionSchema)
+	if err != nil || schema != nil {
+		return schema, err
+	}
+
+	branches := []interface{}{}
+	for _, dt := range registeredDynamicTypes(typ) {
+		branch, err := typeSchema(name+"."+dt.name, dt.typ)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+
+	return map[string]interface{}{"anyOf": branches}, nil
+}
+
+// setSelectorSchema sets the schema of the selector found at the key path
+// keys of the object schema, optional selectors are removed from the
+// required keys
+func setSelectorSchema(schema map[string]interface{}, keys []string, selector map[string]interface{}, optional bool) {
+	for _, key := range keys[:len(keys)-1] {
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		schema, ok = properties[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+	}
+
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	last := keys[len(keys)-1]
+	properties[last] = selector
+
+	if !optional {
+		return
+	}
+
+	required := []string{}
+	if current, ok := schema["required"].([]string); ok {
+		for _, key := range current {
+			if key != last {
+				required = append(required, key)
+			}
+		}
+	}
+	schema["required"] = required
+}
+
+// selectorSchema returns the schema of the selector of a dynamic type,
+// integer and boolean type names also match their typed value
+func selectorSchema(name string) map[string]interface{} {
+	if i, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return map[string]interface{}{"enum": []interface{}{name, i}}
+	}
+
+	if b, err := strconv.ParseBool(name); err == nil && name == strconv.FormatBool(b) {
+		return map[string]interface{}{"enum": []interface{}{name, b}}
+	}
+
+	return map[string]interface{}{"const": name}
+}
+
+// getTypeKind is the reflect.Type counterpart of getKind, Optional holds
+// any raw value and is reported as an interface, times are written as
+// strings
+func getTypeKind(typ reflect.Type) reflect.Kind {
+	if typ == optionalType {
+		return reflect.Interface
+	}
+	if typ == timeType {
+		return reflect.String
+	}
+	return getKind(reflect.Zero(typ))
+}
@@ -0,0 +1,113 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestJSONSchemaSimple(t *testing.T) {
+
+	type ExtraTyp struct {
+		Twitter string `mirror:"twitter,optional"`
+	}
+
+	type Person struct {
+		Name    string        `mirror:"name"`
+		Age     uint          `mirror:"age"`
+		Emails  []string      `mirror:"emails"`
+		Scores  [2]int        `mirror:"scores"`
+		Extra   ExtraTyp      `mirror:"extra"`
+		Born    time.Time     `mirror:"born"`
+		Timeout time.Duration `mirror:"timeout"`
+		Nick    *string       `mirror:"nick"`
+	}
+
+	want := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["name", "age", "emails", "scores", "extra", "born", "timeout", "nick"],
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer", "minimum": 0},
+			"emails": {"type": ["array", "null"], "items": {"type": "string"}},
+			"scores": {"type": "array", "items": {"type": "integer"}, "maxItems": 2},
+			"born": {"type": "string", "format": "date-time"},
+			"timeout": {"type": ["integer", "string"]},
+			"nick": {"type": ["string", "null"]},
+			"extra": {
+				"type": "object",
+				"additionalProperties": false,
+				"required": [],
+				"properties": {
+					"twitter": {"type": ["string", "null"]}
+				}
+			}
+		}
+	}`
+
+	schema, err := JSONSchema(&Person{})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, want, string(schema))
+}
+
+func TestJSONSchemaDynamic(t *testing.T) {
+
+	type Person struct {
+		Extra DynTyp `mirror:"extra,dynamic=type"`
+	}
+
+	RegisterDynamicType(&DynTyp{}, "int", int(0))
+	RegisterDynamicType(&DynTyp{}, "string", "")
+
+	want := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["extra"],
+		"properties": {
+			"extra": {
+				"oneOf": [
+					{
+						"type": "object",
+						"additionalProperties": false,
+						"required": ["type", "value"],
+						"properties": {
+							"type": {"const": "int"},
+							"value": {"type": "integer"}
+						}
+					},
+					{
+						"type": "object",
+						"additionalProperties": false,
+						"required": ["type", "value"],
+						"properties": {
+							"type": {"const": "string"},
+							"value": {"type": "string"}
+						}
+					}
+				]
+			}
+		}
+	}`
+
+	schema, err := JSONSchema(Person{})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, want, string(schema))
+}
+
+func TestJSONSchemaErrors(t *testing.T) {
+
+	type Person struct {
+		Name string `c2:"name"`
+	}
+
+	_, err := JSONSchema(Person{})
+	assert.EqualError(t, err, "schema: missing `mirror` tag for struct field: Name")
+
+	_, err = JSONSchema(nil)
+	assert.Error(t, err)
+}
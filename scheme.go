@@ -0,0 +1,342 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// groupVersionKind identifies a registered object type
+type groupVersionKind struct {
+	apiVersion string
+	kind       string
+}
+
+func (gvk groupVersionKind) String() string {
+	if gvk.kind == "" {
+		return gvk.apiVersion
+	}
+	return gvk.apiVersion + " " + gvk.kind
+}
+
+// conversion converts a registered type into another
+type conversion struct {
+	to      reflect.Type
+	convert func(in, out interface{}) error
+}
+
+// Scheme maps the apiVersion and kind of kubernetes style objects to the
+// configuration structures they are decoded into. Registered structures
+// map the apiVersion and kind keys like any other key, as the decoding
+// reports missing and unused keys. Plain configuration files may use a
+// version key instead of apiVersion and no kind.
+type Scheme struct {
+	mu          sync.RWMutex
+	types       map[groupVersionKind]reflect.Type
+	deprecated  map[groupVersionKind]string
+	conversions map[reflect.Type][]conversion
+}
+
+// Visitor is called with every object decoded from a stream, a non nil
+// error stops the decoding
+type Visitor func(obj interface{}) error
+
+// NewScheme returns an empty scheme
+func NewScheme() *Scheme {
+	return &Scheme{
+		types:       make(map[groupVersionKind]reflect.Type),
+		deprecated:  make(map[groupVersionKind]string),
+		conversions: make(map[reflect.Type][]conversion),
+	}
+}
+
+// Register maps apiVersion and kind to the type of obj, a pointer to a
+// configuration structure. The kind is empty for plain configuration files
+// selected by their version only.
+func (s *Scheme) Register(apiVersion, kind string, obj interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.types[groupVersionKind{apiVersion, kind}] = structType(obj)
+}
+
+// Deprecate marks apiVersion and kind as deprecated, decoding them emits a
+// warning with message, which should point at the migration
+func (s *Scheme) Deprecate(apiVersion, kind, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deprecated[groupVersionKind{apiVersion, kind}] = message
+}
+
+// RegisterConversion registers convert, converting a structure of the
+// type of from into a structure of the type of to. convert is called with
+// pointers to both structures. Conversions are chained to reach a type
+// from older versions.
+func (s *Scheme) RegisterConversion(from, to interface{}, convert func(in, out interface{}) error) {
+	fromType, toType := structType(from), structType(to)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.conversions[fromType] {
+		if c.to == toType {
+			s.conversions[fromType][i].convert = convert
+			return
+		}
+	}
+	s.conversions[fromType] = append(s.conversions[fromType], conversion{toType, convert})
+}
+
+// Convert converts the structure in into out, both pointers to registered
+// structures, through the shortest chain of registered conversions
+func (s *Scheme) Convert(in, out interface{}) error {
+	inVal, outVal := reflect.ValueOf(in), reflect.ValueOf(out)
+	if inVal.Kind() != reflect.Ptr || inVal.IsNil() || outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		return fmt.Errorf("convert: in and out must be non nil pointers, got '%T' and '%T'", in, out)
+	}
+
+	path, err := s.conversionPath(inVal.Elem().Type(), outVal.Elem().Type())
+	if err != nil {
+		return fmt.Errorf("convert: %s", err)
+	}
+
+	current := inVal
+	for i, c := range path {
+		next := outVal
+		if i < len(path)-1 {
+			next = reflect.New(c.to)
+		}
+
+		if err := c.convert(current.Interface(), next.Interface()); err != nil {
+			return fmt.Errorf("convert: %s to %s: %s", current.Elem().Type(), c.to, err)
+		}
+		current = next
+	}
+
+	if len(path) == 0 {
+		outVal.Elem().Set(inVal.Elem())
+	}
+
+	return nil
+}
+
+// conversionPath returns the shortest chain of conversions from the type
+// from to the type to
+func (s *Scheme) conversionPath(from, to reflect.Type) ([]conversion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from == to {
+		return nil, nil
+	}
+
+	// Breadth first search, recording the conversion reaching each type
+	reached := map[reflect.Type]conversion{from: {}}
+	previous := map[reflect.Type]reflect.Type{}
+	queue := []reflect.Type{from}
+
+	for len(queue) > 0 {
+		typ := queue[0]
+		queue = queue[1:]
+
+		for _, c := range s.conversions[typ] {
+			if _, ok := reached[c.to]; ok {
+				continue
+			}
+			reached[c.to] = c
+			previous[c.to] = typ
+
+			if c.to != to {
+				queue = append(queue, c.to)
+				continue
+			}
+
+			path := []conversion{}
+			for t := to; t != from; t = previous[t] {
+				path = append([]conversion{reached[t]}, path...)
+			}
+			return path, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no conversion registered from %s to %s", from, to)
+}
+
+// structType returns the type of the structure obj points to
+func structType(obj interface{}) reflect.Type {
+	typ := reflect.TypeOf(obj)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// New returns a pointer to a new structure of the type registered for
+// apiVersion and kind
+func (s *Scheme) New(apiVersion, kind string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	typ, ok := s.types[groupVersionKind{apiVersion, kind}]
+	if ok {
+		return reflect.New(typ).Interface(), nil
+	}
+
+	kinds := []string{}
+	for gvk := range s.types {
+		if gvk.apiVersion == apiVersion {
+			kinds = append(kinds, gvk.kind)
+		}
+	}
+	sort.Strings(kinds)
+
+	if kind == "" {
+		return nil, fmt.Errorf("no type registered for version '%s'", apiVersion)
+	}
+
+	if suggestion, ok := closestName(kind, kinds); ok {
+		return nil, fmt.Errorf("no type registered for apiVersion '%s' kind '%s', did you mean '%s'?", apiVersion, kind, suggestion)
+	}
+
+	return nil, fmt.Errorf("no type registered for apiVersion '%s' kind '%s'", apiVersion, kind)
+}
+
+// Unmarshal decodes the first document of the yaml stream data into the
+// type registered for its version and converts it into out, a pointer to
+// a registered structure, usually the latest version. Older versions keep
+// working through the registered conversions.
+func (s *Scheme) Unmarshal(data []byte, out interface{}, opts ...Option) error {
+	found := false
+
+	err := s.Visit(data, func(obj interface{}) error {
+		found = true
+		if err := s.Convert(obj, out); err != nil {
+			return err
+		}
+		return errStopVisit
+	}, opts...)
+
+	if err != nil && err != errStopVisit {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("unmarshal: no document found")
+	}
+
+	return nil
+}
+
+// errStopVisit stops the visit of a stream after the first document
+var errStopVisit = fmt.Errorf("stop visit")
+
+// Decode decodes every document of the yaml stream data, separated by
+// ---, into the type registered for its apiVersion and kind
+func (s *Scheme) Decode(data []byte, opts ...Option) ([]interface{}, error) {
+	objs := []interface{}{}
+
+	err := s.Visit(data, func(obj interface{}) error {
+		objs = append(objs, obj)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return objs, nil
+}
+
+// Visit decodes every document of the yaml stream data, separated by ---,
+// into the type registered for its apiVersion and kind and calls visit
+// with the decoded object. Empty documents are skipped.
+func (s *Scheme) Visit(data []byte, visit Visitor, opts ...Option) error {
+	o := newOptions(opts)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for i := 0; ; i++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("document %d: unmarshal yaml: %s", i, err)
+		}
+
+		obj, err := s.decodeNode(&node, o)
+		if err != nil {
+			return fmt.Errorf("document %d: %s", i, err)
+		}
+
+		if obj == nil {
+			continue
+		}
+
+		if err := visit(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeNode decodes a single document of a stream, nil for empty ones
+func (s *Scheme) decodeNode(node *yaml.Node, o *options) (interface{}, error) {
+	if o.fsys != nil {
+		err := newIncludeResolver(o).resolveNode(rootDocument, o.dir, node)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal yaml: %s", err)
+		}
+	}
+
+	tree, err := decodeYamlNode(node, o.nodeLimit)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %s", err)
+	}
+
+	if tree == nil {
+		return nil, nil
+	}
+
+	rawmap, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unmarshal yaml: document must be a mapping, got '%T'", tree)
+	}
+
+	// Plain configuration files use a version key, such as version: 2
+	version, ok := rawmap["apiVersion"]
+	if !ok {
+		version = rawmap["version"]
+	}
+
+	apiVersion, _ := selectorString(version)
+	kind, _ := rawmap["kind"].(string)
+	if apiVersion == "" {
+		return nil, fmt.Errorf("missing apiVersion or version")
+	}
+
+	gvk := groupVersionKind{apiVersion, kind}
+
+	obj, err := s.New(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decodeMapLevels(rawmap, obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s: decode map: %s", gvk, err)
+	}
+
+	s.mu.RLock()
+	message, deprecated := s.deprecated[gvk]
+	s.mu.RUnlock()
+
+	if deprecated {
+		o.warn(fmt.Sprintf("%s is deprecated: %s", gvk, message))
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,232 @@
+package mirror
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"testing"
+)
+
+type SchemeMeta struct {
+	Name string `mirror:"name"`
+}
+
+type SchemeDeployment struct {
+	APIVersion string     `mirror:"apiVersion"`
+	Kind       string     `mirror:"kind"`
+	Metadata   SchemeMeta `mirror:"metadata"`
+	Spec       struct {
+		Replicas int `mirror:"replicas"`
+	} `mirror:"spec"`
+}
+
+type SchemeService struct {
+	APIVersion string     `mirror:"apiVersion"`
+	Kind       string     `mirror:"kind"`
+	Metadata   SchemeMeta `mirror:"metadata"`
+	Spec       struct {
+		Port int `mirror:"port"`
+	} `mirror:"spec"`
+}
+
+func newTestScheme() *Scheme {
+	scheme := NewScheme()
+	scheme.Register("apps/v1", "Deployment", &SchemeDeployment{})
+	scheme.Register("v1", "Service", &SchemeService{})
+	return scheme
+}
+
+func TestSchemeDecode(t *testing.T) {
+
+	manifests := []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+---
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  port: 80
+`)
+
+	deployment := &SchemeDeployment{APIVersion: "apps/v1", Kind: "Deployment", Metadata: SchemeMeta{"web"}}
+	deployment.Spec.Replicas = 3
+	service := &SchemeService{APIVersion: "v1", Kind: "Service", Metadata: SchemeMeta{"web"}}
+	service.Spec.Port = 80
+
+	scheme := newTestScheme()
+
+	objs, err := scheme.Decode(manifests)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{deployment, service}, objs)
+
+	names := []string{}
+	err = scheme.Visit(manifests, func(obj interface{}) error {
+		switch o := obj.(type) {
+		case *SchemeDeployment:
+			names = append(names, "deployment/"+o.Metadata.Name)
+		case *SchemeService:
+			names = append(names, "service/"+o.Metadata.Name)
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deployment/web", "service/web"}, names)
+
+	err = scheme.Visit(manifests, func(obj interface{}) error {
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+}
+
+func TestSchemeDecodeErrors(t *testing.T) {
+
+	tests_ok := []struct {
+		name    string
+		data    string
+		wanterr string
+	}{
+		{
+			"unknown kind", "apiVersion: apps/v1\nkind: Deploymnet\n",
+			"document 0: no type registered for apiVersion 'apps/v1' kind 'Deploymnet', did you mean 'Deployment'?",
+		},
+		{
+			"missing version", "apiVersion: v1\nkind: Service\nmetadata: {name: a}\nspec: {port: 1}\n---\nkind: Service\n",
+			"document 1: missing apiVersion or version",
+		},
+		{
+			"missing kind", "apiVersion: v1\n",
+			"document 0: no type registered for version 'v1'",
+		},
+		{
+			"strict keys", "apiVersion: v1\nkind: Service\nmetadata: {name: a}\nspec: {port: 1, host: a}\n",
+			"document 0: v1 Service: decode map: 1 error(s) decoding:\n\n* detected unused keys: host",
+		},
+		{
+			"not a mapping", "- a\n",
+			"document 0: unmarshal yaml: document must be a mapping, got '[]interface {}'",
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+
+			_, err := newTestScheme().Decode([]byte(tt.data))
+
+			assert.EqualError(t, err, tt.wanterr)
+		})
+	}
+}
+
+type SchemeConfigV1 struct {
+	Version int    `mirror:"version"`
+	Server  string `mirror:"server"`
+}
+
+type SchemeConfigV2 struct {
+	Version int    `mirror:"version"`
+	Host    string `mirror:"host"`
+	Port    int    `mirror:"port"`
+}
+
+type SchemeConfigV3 struct {
+	Version int `mirror:"version"`
+	Listen  struct {
+		Host string `mirror:"host"`
+		Port int    `mirror:"port"`
+	} `mirror:"listen"`
+}
+
+func newVersionedScheme() *Scheme {
+	scheme := NewScheme()
+	scheme.Register("1", "", &SchemeConfigV1{})
+	scheme.Register("2", "", &SchemeConfigV2{})
+	scheme.Register("3", "", &SchemeConfigV3{})
+	scheme.Deprecate("1", "", "rename server to host and port, see docs/migration.md")
+
+	scheme.RegisterConversion(&SchemeConfigV1{}, &SchemeConfigV2{}, func(in, out interface{}) error {
+		v1, v2 := in.(*SchemeConfigV1), out.(*SchemeConfigV2)
+		host, port, err := net.SplitHostPort(v1.Server)
+		if err != nil {
+			return err
+		}
+		v2.Version = 2
+		v2.Host = host
+		v2.Port, err = strconv.Atoi(port)
+		return err
+	})
+	scheme.RegisterConversion(&SchemeConfigV2{}, &SchemeConfigV3{}, func(in, out interface{}) error {
+		v2, v3 := in.(*SchemeConfigV2), out.(*SchemeConfigV3)
+		v3.Version = 3
+		v3.Listen.Host = v2.Host
+		v3.Listen.Port = v2.Port
+		return nil
+	})
+	return scheme
+}
+
+func TestSchemeUnmarshalVersions(t *testing.T) {
+
+	var want SchemeConfigV3
+	want.Version = 3
+	want.Listen.Host = "localhost"
+	want.Listen.Port = 8080
+
+	tests_ok := []struct {
+		name         string
+		data         string
+		wantwarnings []string
+	}{
+		{"latest", "version: 3\nlisten: {host: localhost, port: 8080}\n", []string{}},
+		{"one conversion", "version: 2\nhost: localhost\nport: 8080\n", []string{}},
+		{
+			"deprecated", "version: 1\nserver: localhost:8080\n",
+			[]string{"1 is deprecated: rename server to host and port, see docs/migration.md"},
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+
+			warnings := []string{}
+			var config SchemeConfigV3
+
+			err := newVersionedScheme().Unmarshal([]byte(tt.data), &config, WithWarningHandler(func(warning string) {
+				warnings = append(warnings, warning)
+			}))
+
+			assert.NoError(t, err)
+			assert.Equal(t, want, config)
+			assert.Equal(t, tt.wantwarnings, warnings)
+		})
+	}
+}
+
+func TestSchemeUnmarshalVersionsErrors(t *testing.T) {
+
+	scheme := newVersionedScheme()
+	ignore := WithWarningHandler(nil)
+
+	var v1 SchemeConfigV1
+	err := scheme.Unmarshal([]byte("version: 3\nlisten: {host: a, port: 1}\n"), &v1, ignore)
+	assert.EqualError(t, err, "convert: no conversion registered from mirror.SchemeConfigV3 to mirror.SchemeConfigV1")
+
+	var v3 SchemeConfigV3
+	err = scheme.Unmarshal([]byte("version: 1\nserver: localhost\n"), &v3, ignore)
+	assert.EqualError(t, err, "convert: mirror.SchemeConfigV1 to mirror.SchemeConfigV2: address localhost: missing port in address")
+
+	err = scheme.Unmarshal([]byte("version: 4\n"), &v3, ignore)
+	assert.EqualError(t, err, "document 0: no type registered for version '4'")
+
+	err = scheme.Unmarshal([]byte("---\n"), &v3, ignore)
+	assert.EqualError(t, err, "unmarshal: no document found")
+}
@@ -0,0 +1,205 @@
+package mirror
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a single step of a --set path, either a map key or a list
+// index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// setSource is a source applying path=value assignments
+type setSource []string
+
+// SetSource returns a source applying Helm style path=value assignments,
+// such as server.tls.enabled=true or plugins[1].config.valueint=3, on top
+// of the previous sources. Keys containing dots are quoted: labels."a.b"=c
+// or labels["a.b"]=c. Values are converted to the kind of the target field
+// or typed as yaml scalars when the target is not a basic type. Paths not
+// matching the configuration structure are reported by the decoder as
+// unused keys.
+func SetSource(assignments ...string) Source {
+	return setSource(assignments)
+}
+
+func (s setSource) Apply(raw map[string]interface{}, typ reflect.Type) (map[string]interface{}, error) {
+	errors := make([]string, 0)
+
+	for _, assignment := range s {
+		path, value, err := parseAssignment(assignment)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+
+		if path[0].isIndex {
+			errors = append(errors, fmt.Sprintf("'%s' path must start with a key", assignment))
+			continue
+		}
+
+		applied, err := applyAssignment("", raw, path, value, typ)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		raw = applied.(map[string]interface{})
+	}
+
+	if len(errors) > 0 {
+		return nil, &Error{errors}
+	}
+
+	return raw, nil
+}
+
+// parseAssignment splits a path=value assignment into its path segments and
+// its textual value
+func parseAssignment(assignment string) ([]pathSegment, string, error) {
+	path := []pathSegment{}
+	i := 0
+
+	for {
+		switch {
+		case i < len(assignment) && assignment[i] == '"':
+			end := strings.IndexByte(assignment[i+1:], '"')
+			if end < 0 {
+				return nil, "", fmt.Errorf("'%s' unterminated quoted key", assignment)
+			}
+			path = append(path, pathSegment{key: assignment[i+1 : i+1+end]})
+			i += end + 2
+
+		case i < len(assignment) && assignment[i] == '[':
+			end := strings.IndexByte(assignment[i:], ']')
+			if end < 0 {
+				return nil, "", fmt.Errorf("'%s' unterminated index", assignment)
+			}
+
+			inner := assignment[i+1 : i+end]
+			if len(inner) >= 2 && strings.HasPrefix(inner, `"`) && strings.HasSuffix(inner, `"`) {
+				path = append(path, pathSegment{key: inner[1 : len(inner)-1]})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, "", fmt.Errorf("'%s' invalid index '%s'", assignment, inner)
+				}
+				path = append(path, pathSegment{index: index, isIndex: true})
+			}
+			i += end + 1
+
+		default:
+			end := strings.IndexAny(assignment[i:], ".[=")
+			if end < 0 {
+				return nil, "", fmt.Errorf("'%s' expected path=value", assignment)
+			}
+			if end == 0 {
+				return nil, "", fmt.Errorf("'%s' empty key in path", assignment)
+			}
+			path = append(path, pathSegment{key: assignment[i : i+end]})
+			i += end
+		}
+
+		if i >= len(assignment) {
+			return nil, "", fmt.Errorf("'%s' expected path=value", assignment)
+		}
+
+		switch assignment[i] {
+		case '=':
+			return path, assignment[i+1:], nil
+		case '.':
+			i++
+		case '[':
+		default:
+			return nil, "", fmt.Errorf("'%s' unexpected character '%c' in path", assignment, assignment[i])
+		}
+	}
+}
+
+// applyAssignment sets value at path inside data, a tree decoding to typ
+func applyAssignment(name string, data interface{}, path []pathSegment, value string, typ reflect.Type) (interface{}, error) {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if len(path) == 0 {
+		return setValue(name, value, typ)
+	}
+
+	segment := path[0]
+
+	if segment.isIndex {
+		fieldName := name + "[" + strconv.Itoa(segment.index) + "]"
+
+		dataSlice, _ := data.([]interface{})
+		if segment.index > len(dataSlice) {
+			return nil, fmt.Errorf("'%s' index out of range, list has %d items", fieldName, len(dataSlice))
+		}
+
+		var elemType reflect.Type
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			elemType = typ.Elem()
+		}
+
+		merged := append([]interface{}(nil), dataSlice...)
+		if segment.index == len(merged) {
+			merged = append(merged, nil)
+		}
+
+		elem, err := applyAssignment(fieldName, merged[segment.index], path[1:], value, elemType)
+		if err != nil {
+			return nil, err
+		}
+		merged[segment.index] = elem
+
+		return merged, nil
+	}
+
+	fieldName := segment.key
+	if name != "" {
+		fieldName = name + "." + segment.key
+	}
+
+	dataMap, _ := data.(map[string]interface{})
+	merged := make(map[string]interface{}, len(dataMap)+1)
+	for key, value := range dataMap {
+		merged[key] = value
+	}
+
+	var fieldType reflect.Type
+	if field, _, ok := structFieldByTag(typ, segment.key); ok {
+		fieldType = field.Type
+	}
+
+	elem, err := applyAssignment(fieldName, merged[segment.key], path[1:], value, fieldType)
+	if err != nil {
+		return nil, err
+	}
+	merged[segment.key] = elem
+
+	return merged, nil
+}
+
+// setValue types the assigned value as a yaml scalar, unless the kind of
+// the target field is known
+func setValue(name, value string, typ reflect.Type) (interface{}, error) {
+	if typ != nil {
+		switch getTypeKind(typ) {
+		case reflect.Bool, reflect.Int, reflect.Uint, reflect.Float64, reflect.String:
+			return convertScalar(name, value, typ)
+		}
+	}
+
+	var typed interface{}
+	if err := yaml.Unmarshal([]byte(value), &typed); err != nil {
+		return nil, fmt.Errorf("'%s' invalid value '%s': %s", name, value, err)
+	}
+
+	return normalizeTree(typed)
+}
@@ -0,0 +1,109 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAssignment(t *testing.T) {
+	t.Parallel()
+
+	tests_ok := []struct {
+		name       string
+		assignment string
+		want       []pathSegment
+		value      string
+		err        bool
+	}{
+		{"set 1", "server.port=80", []pathSegment{{key: "server"}, {key: "port"}}, "80", false},
+		{"set 2", "plugins[1].name=a=b", []pathSegment{{key: "plugins"}, {index: 1, isIndex: true}, {key: "name"}}, "a=b", false},
+		{"set 3", `labels."a.b=c"=d`, []pathSegment{{key: "labels"}, {key: "a.b=c"}}, "d", false},
+		{"set 4", `labels["a.b"]=`, []pathSegment{{key: "labels"}, {key: "a.b"}}, "", false},
+		{"set 5", "server.port", nil, "", true},
+		{"set 6", "plugins[x]=1", nil, "", true},
+		{"set 7", "server..port=1", nil, "", true},
+		{"set 8", `labels."a=1`, nil, "", true},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, value, err := parseAssignment(tt.assignment)
+
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, path)
+				assert.Equal(t, tt.value, value)
+			}
+		})
+	}
+}
+
+type SetPlugin struct {
+	Name string `mirror:"name"`
+	Size int    `mirror:"size"`
+}
+
+type SetConfig struct {
+	Server  EnvServer   `mirror:"server"`
+	Plugins []SetPlugin `mirror:"plugins"`
+}
+
+func TestSetSource(t *testing.T) {
+
+	base := []byte(`
+server:
+  host: localhost
+  port: 8080
+  tls: false
+  rate: 1.0
+plugins:
+  - name: first
+    size: 1
+`)
+
+	want := SetConfig{
+		Server: EnvServer{Host: "true", Port: 9090, TLS: true, Rate: 1.0},
+		Plugins: []SetPlugin{
+			{Name: "first", Size: 1},
+			{Name: "second", Size: 2},
+		},
+	}
+
+	var config SetConfig
+	err := Load(&config, YamlSource(base), SetSource(
+		"server.port=9090",
+		"server.tls=true",
+		"server.host=true",
+		"plugins[1].name=second",
+		"plugins[1].size=2",
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	raw, err := SetSource(`labels."a.b"=3`, `labels.list=[1, x]`).Apply(map[string]interface{}{}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"labels": map[string]interface{}{"a.b": 3, "list": []interface{}{1, "x"}},
+	}, raw)
+}
+
+func TestSetSourceErrors(t *testing.T) {
+
+	var config SetConfig
+
+	err := Load(&config, SetSource("server.port=http", "plugins[3].name=x", "[0]=1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'server.port' expected type 'int', got unconvertible value: 'http'")
+	assert.Contains(t, err.Error(), "'plugins[3]' index out of range, list has 0 items")
+	assert.Contains(t, err.Error(), "'[0]=1' path must start with a key")
+
+	err = Load(&config, SetSource("server.unknown=1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "detected unused keys: unknown")
+}
@@ -0,0 +1,320 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPollInterval is the interval between two checks of the watched file
+const defaultPollInterval = time.Second
+
+// RestartPolicy is the behaviour of a Store when a reload modifies fields
+// tagged with `reload=restart`
+type RestartPolicy int
+
+const (
+	// RestartFlag swaps the configuration and records the changes, listed
+	// by RestartRequired
+	RestartFlag RestartPolicy = iota
+	// RestartReject keeps the current configuration and fails the reload
+	RestartReject
+)
+
+// StoreOption configures a Store
+type StoreOption func(*Store)
+
+// WithPollInterval sets the interval between two checks of the watched file
+func WithPollInterval(interval time.Duration) StoreOption {
+	return func(s *Store) {
+		s.interval = interval
+	}
+}
+
+// WithValidator sets a function validating every decoded configuration
+// before it replaces the current one
+func WithValidator(validate func(config interface{}) error) StoreOption {
+	return func(s *Store) {
+		s.validate = validate
+	}
+}
+
+// WithErrorHandler sets a function called with the error of every failed
+// reload, the store keeps the last good configuration in that case
+func WithErrorHandler(handler func(err error)) StoreOption {
+	return func(s *Store) {
+		s.onError = handler
+	}
+}
+
+// WithRestartPolicy sets the behaviour of the store when a reload modifies
+// fields tagged with `reload=restart`, RestartFlag by default
+func WithRestartPolicy(policy RestartPolicy) StoreOption {
+	return func(s *Store) {
+		s.restartPolicy = policy
+	}
+}
+
+// Store holds a configuration loaded from a file and reloaded when the file
+// changes. The current configuration is swapped atomically, only when the
+// new file decodes and validates, and subscribers are notified of every
+// swap with the old and the new configuration.
+type Store struct {
+	path     string
+	typ      reflect.Type
+	interval time.Duration
+	validate func(config interface{}) error
+	onError  func(err error)
+
+	restartPolicy RestartPolicy
+
+	value atomic.Value
+
+	// reloadMu serializes reloads
+	reloadMu  sync.Mutex
+	data      []byte
+	failed    []byte
+	failedErr error
+
+	mu          sync.Mutex
+	subscribers []func(old, new interface{})
+	restart     []Change
+	stop        chan struct{}
+	done        chan struct{}
+	// polling is set while the watcher reloads and reports errors
+	polling bool
+}
+
+// NewStore creates a store loading the file at path into a new value of the
+// type config points to, with the codec picked as UnmarshalFile does. It
+// fails if the first load fails.
+func NewStore(path string, config interface{}, opts ...StoreOption) (*Store, error) {
+	typ := reflect.TypeOf(config)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("store: config must be a pointer")
+	}
+
+	s := &Store{
+		path:     path,
+		typ:      typ.Elem(),
+		interval: defaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Load returns the current configuration, a pointer to the config type
+// given to NewStore. It must be treated as read only.
+func (s *Store) Load() interface{} {
+	return s.value.Load()
+}
+
+// Subscribe registers fn to be called after every configuration swap
+func (s *Store) Subscribe(fn func(old, new interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// RestartRequired returns the changes to fields tagged with `reload=restart`
+// applied since the store was created, with the RestartFlag policy
+func (s *Store) RestartRequired() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Change(nil), s.restart...)
+}
+
+// Reload reads and decodes the file, swapping the current configuration if
+// the content changed and the new configuration is valid.
+func (s *Store) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("store: %s", err)
+	}
+
+	old := s.value.Load()
+	if old != nil && bytes.Equal(data, s.data) {
+		return nil
+	}
+
+	// Do not decode the same broken content twice
+	if s.failed != nil && bytes.Equal(data, s.failed) {
+		return s.failedErr
+	}
+
+	config, err := s.decode(data)
+	if err != nil {
+		s.failed, s.failedErr = data, err
+		return err
+	}
+
+	// The validator may depend on more than the content, run it every time
+	if s.validate != nil {
+		if err := s.validate(config); err != nil {
+			return fmt.Errorf("store: %s: validate: %s", s.path, err)
+		}
+	}
+
+	if old == nil {
+		s.data = data
+		s.value.Store(config)
+		return nil
+	}
+
+	restart := []Change{}
+	for _, change := range Diff(old, config) {
+		if change.Restart {
+			restart = append(restart, change)
+		}
+	}
+
+	if len(restart) > 0 && s.restartPolicy == RestartReject {
+		paths := make([]string, len(restart))
+		for i, change := range restart {
+			paths[i] = change.Path
+		}
+
+		err := fmt.Errorf("store: %s: fields requiring a restart changed: %s", s.path, strings.Join(paths, " "))
+		s.failed, s.failedErr = data, err
+		return err
+	}
+
+	s.data, s.failed, s.failedErr = data, nil, nil
+	s.value.Store(config)
+
+	s.mu.Lock()
+	s.restart = append(s.restart, restart...)
+	subscribers := make([]func(old, new interface{}), len(s.subscribers))
+	copy(subscribers, s.subscribers)
+	s.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, config)
+	}
+
+	return nil
+}
+
+// decode decodes data into a new configuration
+func (s *Store) decode(data []byte) (interface{}, error) {
+	codec, err := codecFor(s.path, data)
+	if err != nil {
+		return nil, fmt.Errorf("store: %s", err)
+	}
+
+	config := reflect.New(s.typ).Interface()
+	if err := unmarshal(codec, data, config, nil); err != nil {
+		return nil, fmt.Errorf("store: %s: %s", s.path, err)
+	}
+
+	return config, nil
+}
+
+// Start watches the file, polling it for changes until Close is called
+func (s *Store) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.watch(s.stop, s.done)
+}
+
+// Close stops watching the file and waits for the watcher to exit. A
+// watcher in the middle of a reload exits once it is over, without being
+// waited for, so that subscribers and the error handler may call Close.
+func (s *Store) Close() {
+	s.mu.Lock()
+	stop, done, polling := s.stop, s.done, s.polling
+	s.stop, s.done = nil, nil
+	if stop != nil {
+		close(stop)
+	}
+	s.mu.Unlock()
+
+	if stop != nil && !polling {
+		<-done
+	}
+}
+
+func (s *Store) watch(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	// Report every failure once, until it changes. Read errors are new
+	// values on every poll, compare their messages
+	reported := ""
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !s.startPolling(stop) {
+				return
+			}
+			reported = s.poll(reported)
+			s.stopPolling()
+		}
+	}
+}
+
+// poll reloads the file, reporting an error unless it is the last reported
+// one, and returns the message of the error
+func (s *Store) poll(reported string) string {
+	err := s.Reload()
+	if err == nil {
+		return ""
+	}
+
+	if err.Error() != reported && s.onError != nil {
+		s.onError(err)
+	}
+	return err.Error()
+}
+
+// startPolling marks the watcher as reloading, unless Close was called
+func (s *Store) startPolling(stop chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-stop:
+		return false
+	default:
+	}
+
+	s.polling = true
+	return true
+}
+
+func (s *Store) stopPolling() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.polling = false
+}
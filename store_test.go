@@ -0,0 +1,229 @@
+package mirror
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type StoreConfig struct {
+	Name string `mirror:"name"`
+	Port int    `mirror:"port"`
+}
+
+// writeFile replaces the file atomically so that the watcher never reads a
+// partial content
+func writeFile(t *testing.T, path, content string) {
+	err := ioutil.WriteFile(path+".tmp", []byte(content), 0644)
+	assert.NoError(t, err)
+
+	err = os.Rename(path+".tmp", path)
+	assert.NoError(t, err)
+}
+
+func TestStoreReload(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "name: first\nport: 80\n")
+
+	store, err := NewStore(path, &StoreConfig{}, WithValidator(func(config interface{}) error {
+		if config.(*StoreConfig).Port == 0 {
+			return fmt.Errorf("port must be set")
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, &StoreConfig{Name: "first", Port: 80}, store.Load())
+
+	var changes [][2]interface{}
+	store.Subscribe(func(old, new interface{}) {
+		changes = append(changes, [2]interface{}{old, new})
+	})
+
+	// Unchanged content does not notify
+	assert.NoError(t, store.Reload())
+	assert.Len(t, changes, 0)
+
+	writeFile(t, path, "name: second\nport: 81\n")
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, &StoreConfig{Name: "second", Port: 81}, store.Load())
+	assert.Equal(t, [][2]interface{}{
+		{&StoreConfig{Name: "first", Port: 80}, &StoreConfig{Name: "second", Port: 81}},
+	}, changes)
+
+	// Broken and invalid files keep the last good configuration
+	writeFile(t, path, "name: third\n")
+	assert.Error(t, store.Reload())
+	writeFile(t, path, "name: third\nport: 0\n")
+	assert.Error(t, store.Reload())
+	assert.Equal(t, &StoreConfig{Name: "second", Port: 81}, store.Load())
+	assert.Len(t, changes, 1)
+}
+
+func TestStoreWatch(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	writeFile(t, path, "name: first\nport: 80\n")
+
+	errs := make(chan error, 10)
+	updates := make(chan interface{}, 10)
+
+	store, err := NewStore(path, &StoreConfig{},
+		WithPollInterval(5*time.Millisecond),
+		WithErrorHandler(func(err error) { errs <- err }))
+	assert.NoError(t, err)
+
+	store.Subscribe(func(old, new interface{}) { updates <- new })
+	store.Start()
+	defer store.Close()
+
+	writeFile(t, path, "name: [")
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "unmarshal yaml")
+	case <-time.After(time.Second):
+		t.Fatal("reload error not reported")
+	}
+
+	writeFile(t, path, "name: second\nport: 81\n")
+	select {
+	case config := <-updates:
+		assert.Equal(t, &StoreConfig{Name: "second", Port: 81}, config)
+	case <-time.After(time.Second):
+		t.Fatal("reload not notified")
+	}
+
+	// A missing file, as during a non atomic save, is reported once
+	assert.NoError(t, os.Remove(path))
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "config.yml")
+	case <-time.After(time.Second):
+		t.Fatal("read error not reported")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, errs, 0)
+}
+
+func TestStoreRestart(t *testing.T) {
+
+	type Config struct {
+		Name string `mirror:"name"`
+		Port int    `mirror:"port,reload=restart"`
+	}
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "name: first\nport: 80\n")
+
+	flagged, err := NewStore(path, &Config{})
+	assert.NoError(t, err)
+
+	rejecting, err := NewStore(path, &Config{}, WithRestartPolicy(RestartReject))
+	assert.NoError(t, err)
+
+	writeFile(t, path, "name: second\nport: 81\n")
+
+	assert.NoError(t, flagged.Reload())
+	assert.Equal(t, &Config{Name: "second", Port: 81}, flagged.Load())
+	assert.Equal(t, []Change{{"port", 80, 81, ChangeModified, true}}, flagged.RestartRequired())
+
+	err = rejecting.Reload()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "fields requiring a restart changed: port")
+	assert.Equal(t, &Config{Name: "first", Port: 80}, rejecting.Load())
+
+	writeFile(t, path, "name: second\nport: 80\n")
+	assert.NoError(t, rejecting.Reload())
+	assert.Equal(t, &Config{Name: "second", Port: 80}, rejecting.Load())
+}
+
+func TestStoreValidatorState(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "name: first\nport: 80\n")
+
+	maxPort := 80
+	store, err := NewStore(path, &StoreConfig{}, WithValidator(func(config interface{}) error {
+		if config.(*StoreConfig).Port > maxPort {
+			return fmt.Errorf("port above %d", maxPort)
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+
+	writeFile(t, path, "name: second\nport: 81\n")
+	assert.Error(t, store.Reload())
+
+	// The same content is validated again
+	maxPort = 81
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, &StoreConfig{Name: "second", Port: 81}, store.Load())
+}
+
+func TestStoreCloseFromSubscriber(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "name: first\nport: 80\n")
+
+	closed := make(chan struct{})
+	store, err := NewStore(path, &StoreConfig{}, WithPollInterval(5*time.Millisecond))
+	assert.NoError(t, err)
+
+	store.Subscribe(func(old, new interface{}) {
+		store.Close()
+		close(closed)
+	})
+	store.Start()
+
+	writeFile(t, path, "name: second\nport: 81\n")
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close from a subscriber blocked")
+	}
+
+	store.Close()
+
+	var errStore *Store
+	reported := make(chan struct{})
+	errStore, err = NewStore(path, &StoreConfig{},
+		WithPollInterval(5*time.Millisecond),
+		WithErrorHandler(func(err error) {
+			errStore.Close()
+			close(reported)
+		}))
+	assert.NoError(t, err)
+	errStore.Start()
+
+	writeFile(t, path, "name: [")
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Fatal("close from the error handler blocked")
+	}
+}
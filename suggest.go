@@ -0,0 +1,126 @@
+package mirror
+
+import (
+	"sort"
+)
+
+// suggestKeys pairs the unused keys of a map with the missing keys of the
+// struct it is decoded into, each key is paired at most once, closest
+// pairs first. The result maps every paired unused key to its suggestion.
+func suggestKeys(missing, unused []string) map[string]string {
+	type pair struct {
+		unused, missing string
+		distance        int
+	}
+
+	pairs := []pair{}
+	for _, u := range unused {
+		for _, m := range missing {
+			if m == "" {
+				continue
+			}
+
+			if d := editDistance(u, m); isClose(u, m, d) {
+				pairs = append(pairs, pair{u, m, d})
+			}
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].distance != pairs[j].distance {
+			return pairs[i].distance < pairs[j].distance
+		}
+		if pairs[i].unused != pairs[j].unused {
+			return pairs[i].unused < pairs[j].unused
+		}
+		return pairs[i].missing < pairs[j].missing
+	})
+
+	suggestions := make(map[string]string)
+	suggested := make(map[string]bool)
+	for _, p := range pairs {
+		if _, ok := suggestions[p.unused]; ok || suggested[p.missing] {
+			continue
+		}
+		suggestions[p.unused] = p.missing
+		suggested[p.missing] = true
+	}
+
+	return suggestions
+}
+
+// closestName returns the candidate closest to name, if close enough
+func closestName(name string, candidates []string) (string, bool) {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := editDistance(name, candidate)
+		if isClose(name, candidate, d) && (bestDistance < 0 || d < bestDistance) {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best, bestDistance >= 0
+}
+
+// isClose reports if two names at edit distance d are likely a typo of
+// each other: at most half of the longest name differs
+func isClose(a, b string, d int) bool {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return d*2 <= longest
+}
+
+// editDistance returns the levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// removeMissingErrors removes from errors the missing key errors of the
+// keys suggested for an unused key
+func removeMissingErrors(errors []string, suggestions map[string]string) []string {
+	suggested := make(map[string]bool, len(suggestions))
+	for _, missing := range suggestions {
+		suggested["map value not found for key: "+missing] = true
+	}
+
+	kept := make([]string, 0, len(errors))
+	for _, err := range errors {
+		if !suggested[err] {
+			kept = append(kept, err)
+		}
+	}
+	return kept
+}
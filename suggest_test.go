@@ -0,0 +1,84 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type SuggestPlugin struct {
+	Type   string      `mirror:"type"`
+	Config interface{} `mirror:"config"`
+}
+
+func (p *SuggestPlugin) SetDynamicType(Type string) {
+	switch Type {
+	case "kafka":
+		p.Config = &SuggestKafka{}
+	case "redis":
+		p.Config = &SuggestRedis{}
+	}
+}
+
+type SuggestKafka struct {
+	Brokers []string `mirror:"brokers"`
+}
+
+type SuggestRedis struct {
+	Address string `mirror:"address"`
+}
+
+func TestEditDistance(t *testing.T) {
+
+	tests_ok := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"twit", "twitter", 3},
+		{"kafak", "kafka", 2},
+		{"port", "prot", 2},
+		{"hôst", "host", 1},
+	}
+	for _, tt := range tests_ok {
+		assert.Equal(t, tt.want, editDistance(tt.a, tt.b), tt.a+" "+tt.b)
+	}
+
+	assert.Equal(t, map[string]string{"timeuot": "timeout", "prot": "port"},
+		suggestKeys([]string{"port", "timeout", "host"}, []string{"prot", "timeuot", "unrelated"}))
+}
+
+func TestDecodeSuggestions(t *testing.T) {
+
+	RegisterDynamicType(&SuggestPlugin{}, "kafka", &SuggestKafka{})
+	RegisterDynamicType(&SuggestPlugin{}, "redis", &SuggestRedis{})
+
+	type Config struct {
+		Name    string          `mirror:"name"`
+		Plugins []SuggestPlugin `mirror:"plugins,dynamic=type"`
+		Cache   SuggestPlugin   `mirror:"cache,dynamic=type"`
+	}
+
+	yamlContent := []byte(`
+nmae: suggest
+extra: 1
+plugins:
+  - type: kafak
+    config: {}
+  - type: mysql
+    config: {}
+cache:
+  type: redis
+  config:
+    adress: localhost
+`)
+
+	var config Config
+	err := UnmarshalYaml(yamlContent, &config)
+
+	assert.EqualError(t, err, "decode map: 5 error(s) decoding:\n\n"+
+		"* 'Plugins[0]' unknown dynamic type 'kafak', did you mean 'kafka'?\n"+
+		"* 'Plugins[1]' unknown dynamic type 'mysql', known types: kafka, redis\n"+
+		"* cache.config.adress: unknown key, did you mean 'address'?\n"+
+		"* detected unused keys: extra\n"+
+		"* nmae: unknown key, did you mean 'name'?")
+}
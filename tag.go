@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fieldTag holds the parsed content of a `mirror` struct tag:
+// the map key followed by a comma separated list of options. The `desc=`
+// option must be the last one, its text takes the rest of the tag and may
+// contain commas.
+type fieldTag struct {
+	Name       string
+	Dynamic    string
+	DynDefault string
+	Union      string
+	Optional   bool
+	Merge      string
+	Env        string
+	Desc       string
+	Reload     string
+}
+
+// reloadRestart is the `reload=` option of fields that cannot change at
+// runtime
+const reloadRestart = "restart"
+
+// parseTag parses the `mirror` tag of a struct field, an empty Name
+// means the tag is missing.
+func parseTag(field reflect.StructField) (fieldTag, error) {
+	tags := field.Tag.Get("mirror")
+
+	desc := ""
+	if i := strings.Index(tags, ",desc="); i >= 0 {
+		tags, desc = tags[:i], tags[i+len(",desc="):]
+	}
+	tagSlice := strings.Split(tags, ",")
+
+	// Optional fields record the absence of their key
+	tag := fieldTag{Name: tagSlice[0], Optional: field.Type == optionalType, Desc: desc}
+
+	for _, option := range tagSlice[1:] {
+		switch {
+		case strings.HasPrefix(option, "dynamic="):
+			tag.Dynamic = strings.TrimPrefix(option, "dynamic=")
+			if !validSelector(tag.Dynamic) {
+				return tag, fmt.Errorf("invalid dynamic selector '%s' for struct field: %s", tag.Dynamic, field.Name)
+			}
+		case strings.HasPrefix(option, "dyndefault="):
+			tag.DynDefault = strings.TrimPrefix(option, "dyndefault=")
+		case option == "union="+unionExternal, option == "union="+unionUntagged:
+			tag.Union = strings.TrimPrefix(option, "union=")
+		case option == "optional":
+			tag.Optional = true
+		case strings.HasPrefix(option, "merge="):
+			tag.Merge = strings.TrimPrefix(option, "merge=")
+			if !validMergeStrategy(tag.Merge) {
+				return tag, fmt.Errorf("invalid merge strategy '%s' for struct field: %s", tag.Merge, field.Name)
+			}
+		case strings.HasPrefix(option, "env="):
+			tag.Env = strings.TrimPrefix(option, "env=")
+		case option == "reload="+reloadRestart:
+			tag.Reload = reloadRestart
+		default:
+			return tag, fmt.Errorf("invalid tag option '%s' for struct field: %s", option, field.Name)
+		}
+	}
+
+	// The type name of an external union is copied to a key of the element,
+	// untagged unions have no selector
+	if (tag.Union == unionExternal && strings.ContainsAny(tag.Dynamic, "./")) ||
+		(tag.Union == unionUntagged && tag.Dynamic != "") {
+		return tag, fmt.Errorf("invalid dynamic selector '%s' for union struct field: %s", tag.Dynamic, field.Name)
+	}
+
+	return tag, nil
+}
+
+// structFieldByTag returns the field of the struct type typ mapped to key
+func structFieldByTag(typ reflect.Type, key string) (reflect.StructField, fieldTag, bool) {
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return reflect.StructField{}, fieldTag{}, false
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag, err := parseTag(field)
+		if err != nil || tag.Name != key {
+			continue
+		}
+
+		return field, tag, true
+	}
+
+	return reflect.StructField{}, fieldTag{}, false
+}
@@ -0,0 +1,36 @@
+package mirror
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+)
+
+// Unmarshal full toml into the configuration structure
+func UnmarshalToml(data []byte, config interface{}, opts ...Option) error {
+	return unmarshal(tomlCodec{}, data, config, opts)
+}
+
+// TomlSource returns a source deep merging the toml document data over the
+// previous sources
+func TomlSource(data []byte, opts ...Option) Source {
+	return CodecSource(tomlCodec{}, data, opts...)
+}
+
+// parseToml parses a toml document into a raw tree, arrays of tables become
+// slices of maps as in yaml and json trees and datetimes are kept as
+// time.Time values.
+func parseToml(data []byte, o *options) (map[string]interface{}, error) {
+	rawmap := make(map[string]interface{})
+
+	_, err := toml.Decode(string(data), &rawmap)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal toml: %s", err)
+	}
+
+	normalized, err := normalizeTree(rawmap)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal toml: %s", err)
+	}
+
+	return normalized.(map[string]interface{}), nil
+}
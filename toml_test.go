@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type TomlPlugin struct {
+	Name  string      `mirror:"name"`
+	Type  string      `mirror:"type"`
+	Value interface{} `mirror:"value"`
+}
+
+func (p *TomlPlugin) SetDynamicType(Type string) {
+	switch Type {
+	case "int":
+		p.Value = int(0)
+	case "string":
+		p.Value = ""
+	}
+}
+
+type TomlConfig struct {
+	Name    string       `mirror:"name"`
+	Created time.Time    `mirror:"created"`
+	Ratio   float64      `mirror:"ratio"`
+	Server  LoadServer   `mirror:"server"`
+	Plugins []TomlPlugin `mirror:"plugins,dynamic=type"`
+}
+
+func TestUnmarshalToml(t *testing.T) {
+
+	tomlContent := []byte(`
+name = "toml"
+created = 1979-05-27T07:32:00Z
+ratio = 0.5
+
+[server]
+host = "localhost"
+port = 8080
+
+[[plugins]]
+name = "first"
+type = "int"
+value = 1
+
+[[plugins]]
+name = "second"
+type = "string"
+value = "two"
+`)
+
+	want := TomlConfig{
+		Name:    "toml",
+		Created: time.Date(1979, time.May, 27, 7, 32, 0, 0, time.UTC),
+		Ratio:   0.5,
+		Server:  LoadServer{Host: "localhost", Port: 8080},
+		Plugins: []TomlPlugin{
+			{Name: "first", Type: "int", Value: 1},
+			{Name: "second", Type: "string", Value: "two"},
+		},
+	}
+
+	var config TomlConfig
+	err := UnmarshalToml(tomlContent, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+
+	err = UnmarshalToml([]byte("name = \"toml\"\nunknown = 1\n"), &config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "detected unused keys: unknown")
+
+	err = UnmarshalToml([]byte("name = "), &config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unmarshal toml")
+}
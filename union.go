@@ -0,0 +1,242 @@
+package mirror
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// unionExternal is the `union=` option of the externally tagged unions,
+	// whose single map key names the type of its value: {s3: {bucket: x}}
+	unionExternal = "external"
+	// unionUntagged is the `union=` option of the unions without any
+	// discriminator, whose type is the first registered one decoding the
+	// value without errors
+	unionUntagged = "untagged"
+)
+
+// decodeExternalUnion selects the type of the externally tagged union val,
+// or of each of its elements, from the raw map data and returns the raw
+// tree to decode into val, with every payload moved to the interface field
+// of the dynamic struct.
+func decodeExternalUnion(name string, data reflect.Value, val reflect.Value, tag fieldTag) (interface{}, error) {
+	for data.Kind() == reflect.Interface && !data.IsNil() {
+		data = data.Elem()
+	}
+
+	if data.Kind() != reflect.Slice {
+		return unionElement(name, data, val, tag)
+	}
+
+	valType := val.Type()
+	valSlice := val
+	if valSlice.IsNil() {
+		valSlice = reflect.MakeSlice(valType, data.Len(), data.Len())
+	}
+	for valSlice.Len() < data.Len() {
+		valSlice = reflect.Append(valSlice, reflect.Zero(valType.Elem()))
+	}
+
+	errors := make([]string, 0)
+	elements := make([]interface{}, data.Len())
+
+	for i := 0; i < data.Len(); i++ {
+		element, err := unionElement(fmt.Sprintf("%s[%d]", name, i), data.Index(i), valSlice.Index(i), tag)
+		if err != nil {
+			errors = appendErrors(errors, err)
+			continue
+		}
+		elements[i] = element
+	}
+
+	val.Set(valSlice)
+
+	if len(errors) > 0 {
+		return nil, &Error{errors}
+	}
+
+	return elements, nil
+}
+
+// unionElement sets the dynamic type of a single union element val and
+// returns its raw map in internally tagged form
+func unionElement(name string, data reflect.Value, val reflect.Value, tag fieldTag) (interface{}, error) {
+	typeName, payload, err := externalUnion(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	dyn, ok := val.Addr().Interface().(DynamicStruct)
+	if !ok {
+		return nil, fmt.Errorf("'%s' union type '%s' does not implement DynamicStruct", name, val.Type())
+	}
+
+	payloadTag, ok := unionPayload(val.Type())
+	if !ok {
+		return nil, fmt.Errorf("'%s' union type '%s' needs a single interface field for the payload", name, val.Type())
+	}
+
+	if err := checkDynamicType(name, val.Type(), typeName); err != nil {
+		return nil, err
+	}
+
+	dyn.SetDynamicType(typeName)
+
+	element := map[string]interface{}{payloadTag.Name: payload}
+	if tag.Dynamic != "" {
+		element[tag.Dynamic] = typeName
+	}
+
+	return element, nil
+}
+
+// externalUnion returns the type name and the payload of the raw map of an
+// externally tagged union element, which must hold a single key
+func externalUnion(name string, data reflect.Value) (string, interface{}, error) {
+	for data.Kind() == reflect.Interface && !data.IsNil() {
+		data = data.Elem()
+	}
+
+	if data.Kind() != reflect.Map {
+		return "", nil, fmt.Errorf("'%s' union expects a map with a single key naming the type, got '%v'", name, data)
+	}
+
+	keys := make([]string, 0, data.Len())
+	for _, key := range data.MapKeys() {
+		keys = append(keys, fmt.Sprint(key.Interface()))
+	}
+	sort.Strings(keys)
+
+	switch len(keys) {
+	case 0:
+		return "", nil, fmt.Errorf("'%s' union expects a single key naming the type, got none", name)
+	case 1:
+		return keys[0], data.MapIndex(data.MapKeys()[0]).Interface(), nil
+	default:
+		return "", nil, fmt.Errorf("'%s' union expects a single key naming the type, got %d: %s", name, len(keys), strings.Join(keys, ", "))
+	}
+}
+
+// unionPayload returns the tag of the single interface field of the
+// dynamic struct type typ, which receives the payload of the union
+func unionPayload(typ reflect.Type) (fieldTag, bool) {
+	payload, found := fieldTag{}, 0
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type.Kind() != reflect.Interface {
+			continue
+		}
+
+		tag, err := parseTag(field)
+		if err != nil || tag.Name == "" {
+			continue
+		}
+
+		payload = tag
+		found++
+	}
+
+	return payload, found == 1
+}
+
+// decodeUntaggedUnion decodes data into the untagged union val, or into
+// each of its elements
+func decodeUntaggedUnion(name, key string, data interface{}, val reflect.Value) error {
+	if val.Kind() != reflect.Slice {
+		return untaggedElement(name, key, data, val)
+	}
+
+	dataSlice, ok := data.([]interface{})
+	if !ok {
+		return fmt.Errorf("'%s': source data must be an array or slice, got %T", name, data)
+	}
+
+	valSlice := val
+	if valSlice.IsNil() {
+		valSlice = reflect.MakeSlice(val.Type(), len(dataSlice), len(dataSlice))
+	}
+	for valSlice.Len() < len(dataSlice) {
+		valSlice = reflect.Append(valSlice, reflect.Zero(val.Type().Elem()))
+	}
+
+	errors := make([]string, 0)
+	for i, item := range dataSlice {
+		if err := untaggedElement(fmt.Sprintf("%s[%d]", name, i), fmt.Sprintf("%s[%d]", key, i), item, valSlice.Index(i)); err != nil {
+			errors = appendErrors(errors, err)
+		}
+	}
+
+	val.Set(valSlice)
+
+	if len(errors) > 0 {
+		return &Error{errors}
+	}
+
+	return nil
+}
+
+// untaggedElement tries to decode data into each type registered for the
+// dynamic struct val, in registration order, and selects the first one
+// decoding without errors. The errors of every type are reported when none
+// matches.
+func untaggedElement(name, key string, data interface{}, val reflect.Value) error {
+	dyn, ok := val.Addr().Interface().(DynamicStruct)
+	if !ok {
+		return fmt.Errorf("'%s' union type '%s' does not implement DynamicStruct", name, val.Type())
+	}
+
+	payloadTag, ok := unionPayload(val.Type())
+	if !ok {
+		return fmt.Errorf("'%s' union type '%s' needs a single interface field for the payload", name, val.Type())
+	}
+	payloadField, _, _ := structFieldByTag(val.Type(), payloadTag.Name)
+
+	registered := registeredDynamicTypes(val.Type())
+	if len(registered) == 0 {
+		return fmt.Errorf("'%s' untagged union type '%s' has no registered types", name, val.Type())
+	}
+
+	errors := make([]string, 0)
+	for _, dt := range registered {
+		candidate := reflect.New(dt.typ).Elem()
+
+		if err := decode(name, key, data, candidate); err != nil {
+			for _, candidateErr := range appendErrors(nil, err) {
+				errors = append(errors, fmt.Sprintf("'%s' union type '%s': %s", name, dt.name, candidateErr))
+			}
+			continue
+		}
+
+		dyn.SetDynamicType(dt.name)
+		val.FieldByIndex(payloadField.Index).Set(candidate)
+		return nil
+	}
+
+	return &Error{errors}
+}
+
+// untaggedType returns the name of the registered type of the payload of
+// the untagged union val
+func untaggedType(val reflect.Value) (string, reflect.Value, bool) {
+	payloadTag, ok := unionPayload(val.Type())
+	if !ok {
+		return "", reflect.Value{}, false
+	}
+
+	payloadField, _, _ := structFieldByTag(val.Type(), payloadTag.Name)
+	payload := val.FieldByIndex(payloadField.Index)
+	if payload.IsNil() {
+		return "", reflect.Value{}, false
+	}
+
+	for _, dt := range registeredDynamicTypes(val.Type()) {
+		if payload.Elem().Type() == dt.typ {
+			return dt.name, payload, true
+		}
+	}
+
+	return "", reflect.Value{}, false
+}
@@ -0,0 +1,181 @@
+package mirror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type UnionBackend struct {
+	Type   string      `mirror:"type,optional"`
+	Config interface{} `mirror:"config"`
+}
+
+func (b *UnionBackend) SetDynamicType(Type string) {
+	switch Type {
+	case "s3":
+		b.Config = &UnionS3{}
+	case "local":
+		b.Config = &UnionLocal{}
+	}
+}
+
+type UnionS3 struct {
+	Bucket string `mirror:"bucket"`
+}
+
+type UnionLocal struct {
+	Path string `mirror:"path"`
+}
+
+type UnionConfig struct {
+	Backend  UnionBackend   `mirror:"backend,union=external,dynamic=type"`
+	Replicas []UnionBackend `mirror:"replicas,union=external"`
+}
+
+func TestExternalUnion(t *testing.T) {
+
+	yamlContent := []byte(`
+backend:
+  s3:
+    bucket: x
+replicas:
+  - local:
+      path: /var/backup
+  - s3:
+      bucket: y
+`)
+
+	var want = UnionConfig{
+		Backend: UnionBackend{Type: "s3", Config: &UnionS3{Bucket: "x"}},
+		Replicas: []UnionBackend{
+			{Config: &UnionLocal{Path: "/var/backup"}},
+			{Config: &UnionS3{Bucket: "y"}},
+		},
+	}
+
+	var md Metadata
+	var config UnionConfig
+	err := UnmarshalYaml(yamlContent, &config, WithMetadata(&md))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+	assert.Equal(t, map[string]string{
+		"backend":     "s3",
+		"replicas[0]": "local",
+		"replicas[1]": "s3",
+	}, md.DynamicTypes)
+	assert.Contains(t, md.Keys, "replicas[0].local.path")
+	assert.Empty(t, md.Unused)
+}
+
+func TestExternalUnionErrors(t *testing.T) {
+
+	tests_ok := []struct {
+		name    string
+		data    string
+		wanterr string
+	}{
+		{
+			"no key", "backend: {}\nreplicas: []\n",
+			"'Backend' union expects a single key naming the type, got none",
+		},
+		{
+			"multiple keys", "backend: {s3: {bucket: x}, local: {path: y}}\nreplicas: []\n",
+			"'Backend' union expects a single key naming the type, got 2: local, s3",
+		},
+		{
+			"slice element", "backend: {s3: {bucket: x}}\nreplicas: [{s3: {bucket: x}}, x]\n",
+			"'Replicas[1]' union expects a map with a single key naming the type, got 'x'",
+		},
+		{
+			"payload", "backend: {s3: {bucket: x, region: y}}\nreplicas: []\n",
+			"detected unused keys: region",
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+
+			var config UnionConfig
+			err := UnmarshalYaml([]byte(tt.data), &config)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wanterr)
+		})
+	}
+}
+
+func TestExternalUnionSchema(t *testing.T) {
+
+	RegisterDynamicType(&UnionBackend{}, "s3", &UnionS3{})
+	RegisterDynamicType(&UnionBackend{}, "local", &UnionLocal{})
+
+	data, err := JSONSchema(&UnionConfig{})
+	assert.NoError(t, err)
+
+	assert.Contains(t, string(data), `"oneOf": [
+        {
+          "additionalProperties": false,
+          "properties": {
+            "s3": {`)
+	assert.Contains(t, string(data), `"required": [
+              "local"
+            ],`)
+}
+
+type UnionImage struct {
+	Value interface{} `mirror:"value"`
+}
+
+func (i *UnionImage) SetDynamicType(Type string) {}
+
+type UnionImageSpec struct {
+	Name string `mirror:"name"`
+	Tag  string `mirror:"tag"`
+}
+
+func TestUntaggedUnion(t *testing.T) {
+
+	RegisterDynamicType(&UnionImage{}, "shorthand", "")
+	RegisterDynamicType(&UnionImage{}, "spec", &UnionImageSpec{})
+
+	type Config struct {
+		Image    UnionImage   `mirror:"image,union=untagged"`
+		Sidecars []UnionImage `mirror:"sidecars,union=untagged"`
+	}
+
+	yamlContent := []byte(`
+image: nginx
+sidecars:
+  - {name: envoy, tag: "1.2"}
+  - busybox
+`)
+
+	var want = Config{
+		Image: UnionImage{Value: "nginx"},
+		Sidecars: []UnionImage{
+			{Value: &UnionImageSpec{Name: "envoy", Tag: "1.2"}},
+			{Value: "busybox"},
+		},
+	}
+
+	var md Metadata
+	var config Config
+	err := UnmarshalYaml(yamlContent, &config, WithMetadata(&md))
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+	assert.Equal(t, map[string]string{
+		"image":       "shorthand",
+		"sidecars[0]": "spec",
+		"sidecars[1]": "shorthand",
+	}, md.DynamicTypes)
+	assert.Contains(t, md.Keys, "sidecars[0].tag")
+
+	config = Config{}
+	err = UnmarshalYaml([]byte("image: {name: nginx}\nsidecars: []\n"), &config)
+
+	assert.EqualError(t, err, "decode map: 2 error(s) decoding:\n\n"+
+		"* 'Image' union type 'shorthand': 'Image' expected type 'string', got unconvertible type 'map[string]interface {}', value: 'map[name:nginx]'\n"+
+		"* 'Image' union type 'spec': map value not found for key: tag")
+}
@@ -0,0 +1,221 @@
+package mirror
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultNodeLimit is the default maximum number of nodes of a yaml
+// document once its aliases are expanded
+const defaultNodeLimit = 1 << 20
+
+// mergeTag is the tag yaml resolves the `<<` merge key to
+const mergeTag = "!!merge"
+
+// yamlBools holds the yaml 1.1 boolean words that yaml 1.2 resolves to
+// strings
+var yamlBools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"on": true, "On": true, "ON": true,
+	"off": false, "Off": false, "OFF": false,
+}
+
+// yamlBoolWord is a plain, unquoted, yaml scalar spelling a yaml 1.1
+// boolean word. It decodes as written into string fields and as the
+// boolean it spells into bool fields, quoted scalars and the strings of
+// the other formats stay plain strings.
+type yamlBoolWord string
+
+// plainTree returns tree with its yamlBoolWord values turned back into
+// plain strings, for the raw values leaving the decoder
+func plainTree(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case yamlBoolWord:
+		return string(t)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[key] = plainTree(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, value := range t {
+			s[i] = plainTree(value)
+		}
+		return s
+	default:
+		return tree
+	}
+}
+
+// yamlExpander builds the raw tree of a yaml document, expanding aliases
+// and merge keys before the struct mapping sees it
+type yamlExpander struct {
+	limit int
+	nodes int
+	// expanding holds the anchored nodes being expanded, for cycle detection
+	expanding map[*yaml.Node]bool
+	// alias is the outermost alias being expanded, reported by the errors
+	alias *yaml.Node
+}
+
+// decodeYamlNode decodes a parsed yaml document into a raw tree with string
+// keys, following the yaml 1.2 scalar rules: yes, no, on and off are
+// strings, marked as yamlBoolWord when plain. Timestamps are kept as
+// strings, decoded by string fields as written and parsed by time.Time
+// fields. Aliases are expanded and `<<` merge keys are merged into their
+// mapping, the expanded document may hold at most limit nodes.
+func decodeYamlNode(node *yaml.Node, limit int) (interface{}, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+
+	e := &yamlExpander{limit: limit, expanding: make(map[*yaml.Node]bool)}
+	return e.expand(node)
+}
+
+func (e *yamlExpander) expand(node *yaml.Node) (interface{}, error) {
+	e.nodes++
+	if e.nodes > e.limit {
+		at := node
+		if e.alias != nil {
+			at = e.alias
+		}
+		return nil, fmt.Errorf("%d:%d: yaml node limit of %d exceeded", at.Line, at.Column, e.limit)
+	}
+
+	if node.Anchor != "" {
+		if e.expanding[node] {
+			return nil, fmt.Errorf("%d:%d: anchor '%s' value contains itself", node.Line, node.Column, node.Anchor)
+		}
+
+		e.expanding[node] = true
+		defer delete(e.expanding, node)
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return e.expand(node.Content[0])
+
+	case yaml.AliasNode:
+		if e.expanding[node.Alias] {
+			return nil, fmt.Errorf("%d:%d: anchor '%s' value contains itself", node.Line, node.Column, node.Value)
+		}
+
+		if e.alias == nil {
+			e.alias = node
+			defer func() { e.alias = nil }()
+		}
+		return e.expand(node.Alias)
+
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(node.Content))
+		for i, child := range node.Content {
+			value, err := e.expand(child)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = value
+		}
+		return s, nil
+
+	case yaml.MappingNode:
+		return e.expandMapping(node)
+
+	default:
+		if node.ShortTag() == "!!timestamp" {
+			return node.Value, nil
+		}
+
+		if _, ok := yamlBools[node.Value]; ok && node.Style == 0 && node.ShortTag() == "!!str" {
+			return yamlBoolWord(node.Value), nil
+		}
+
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+// expandMapping builds the map of a mapping node. The keys set explicitly
+// take precedence over the merged ones, and the mappings merged first over
+// the following ones.
+func (e *yamlExpander) expandMapping(node *yaml.Node) (interface{}, error) {
+	explicit := make(map[string]interface{}, len(node.Content)/2)
+	merged := make([]map[string]interface{}, 0)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		value, err := e.expand(valueNode)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyNode.Kind == yaml.ScalarNode && keyNode.ShortTag() == mergeTag {
+			maps, err := mergedMappings(keyNode, value)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, maps...)
+			continue
+		}
+
+		key, err := e.expand(keyNode)
+		if err != nil {
+			return nil, err
+		}
+
+		if word, ok := key.(yamlBoolWord); ok {
+			key = string(word)
+		}
+
+		skey, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%d:%d: non string key '%v'", keyNode.Line, keyNode.Column, key)
+		}
+		explicit[skey] = value
+	}
+
+	m := make(map[string]interface{}, len(explicit))
+	for i := len(merged) - 1; i >= 0; i-- {
+		for key, value := range merged[i] {
+			m[key] = value
+		}
+	}
+	for key, value := range explicit {
+		m[key] = value
+	}
+
+	return m, nil
+}
+
+// mergedMappings returns the mappings of the value of a merge key, a
+// mapping or a sequence of mappings
+func mergedMappings(keyNode *yaml.Node, value interface{}) ([]map[string]interface{}, error) {
+	err := fmt.Errorf("%d:%d: merge key expects a mapping or a sequence of mappings", keyNode.Line, keyNode.Column)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}, nil
+	case []interface{}:
+		maps := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, err
+			}
+			maps[i] = m
+		}
+		return maps, nil
+	default:
+		return nil, err
+	}
+}
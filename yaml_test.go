@@ -0,0 +1,109 @@
+package mirror
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type AnchorServer struct {
+	Host    string `mirror:"host"`
+	Port    int    `mirror:"port"`
+	Timeout int    `mirror:"timeout"`
+}
+
+type AnchorLimits struct {
+	Timeout int `mirror:"timeout"`
+}
+
+type AnchorConfig struct {
+	Defaults AnchorServer   `mirror:"defaults"`
+	Limits   AnchorLimits   `mirror:"limits"`
+	Servers  []AnchorServer `mirror:"servers"`
+}
+
+func TestUnmarshalYamlAnchors(t *testing.T) {
+
+	yamlContent := []byte(`
+defaults: &defaults
+  host: localhost
+  port: 80
+  timeout: 30
+limits: &limits
+  timeout: 5
+servers:
+  - <<: *defaults
+  - <<: *defaults
+    port: 8080
+  - <<: [*limits, *defaults]
+    host: remote
+  - *defaults
+`)
+
+	var want = AnchorConfig{
+		Defaults: AnchorServer{Host: "localhost", Port: 80, Timeout: 30},
+		Limits:   AnchorLimits{Timeout: 5},
+		Servers: []AnchorServer{
+			{Host: "localhost", Port: 80, Timeout: 30},
+			{Host: "localhost", Port: 8080, Timeout: 30},
+			{Host: "remote", Port: 80, Timeout: 5},
+			{Host: "localhost", Port: 80, Timeout: 30},
+		},
+	}
+
+	// The merged keys are counted as used, no unused key is reported
+	var config AnchorConfig
+	err := UnmarshalYaml(yamlContent, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, config)
+}
+
+func TestUnmarshalYamlAnchorsErrors(t *testing.T) {
+
+	// Each level doubles the nodes of the previous one
+	var bomb strings.Builder
+	bomb.WriteString("a0: &a0 [x, x]\n")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&bomb, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+	}
+
+	tests_ok := []struct {
+		name    string
+		data    string
+		opts    []Option
+		wanterr string
+	}{
+		{
+			"node limit", "a: &a [x, x, x]\nb: [*a, *a]\n",
+			[]Option{WithNodeLimit(10)},
+			"unmarshal yaml: 2:5: yaml node limit of 10 exceeded",
+		},
+		{
+			"alias bomb", bomb.String(),
+			nil,
+			"yaml node limit of 1048576 exceeded",
+		},
+		{
+			"self reference", "a: &a [x, *a]\n",
+			nil,
+			"unmarshal yaml: 1:11: anchor 'a' value contains itself",
+		},
+		{
+			"merge scalar", "a: &a x\nb:\n  <<: *a\n",
+			nil,
+			"unmarshal yaml: 3:3: merge key expects a mapping or a sequence of mappings",
+		},
+	}
+	for _, tt := range tests_ok {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+
+			_, err := parseYaml([]byte(tt.data), newOptions(tt.opts))
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wanterr)
+		})
+	}
+}